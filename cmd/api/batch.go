@@ -0,0 +1,303 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/goddhi/zeliz-movie/internal/i18n"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+// maxBatchOperations caps how many operations a single batch request may contain.
+const maxBatchOperations = 1000
+
+// Batch modes. In atomic mode every operation runs in one transaction which is rolled
+// back if any of them fails; in best-effort mode each operation stands on its own.
+const (
+	batchModeAtomic     = "atomic"
+	batchModeBestEffort = "best_effort"
+)
+
+// Batch operation result statuses.
+const (
+	batchStatusOK         = "ok"
+	batchStatusFailed     = "failed"
+	batchStatusRolledBack = "rolled_back"
+)
+
+// batchOperation is a single create, update or delete in a batch request. For updates,
+// Movie only needs to hold the fields being changed, and Version (if given) must match
+// the stored version.
+type batchOperation struct {
+	Op      string      `json:"op"`
+	ID      int64       `json:"id"`
+	Version *int32      `json:"version"`
+	Movie   *movieInput `json:"movie"`
+}
+
+// batchResult reports what happened to one operation, in request order.
+type batchResult struct {
+	Index   int                               `json:"index"`
+	Op      string                            `json:"op"`
+	Status  string                            `json:"status"`
+	ID      int64                             `json:"id,omitempty"`
+	Version int32                             `json:"version,omitempty"`
+	Error   string                            `json:"error,omitempty"`
+	Errors  map[string]string                 `json:"errors,omitempty"`
+	Details map[string][]validator.FieldError `json:"details,omitempty"`
+
+	movie *data.Movie // the created or updated movie, for updating the similar movies index
+}
+
+// movieStore is implemented by both the MovieModel and a MovieTx, so operations can be
+// run against either.
+type movieStore interface {
+	Insert(movie *data.Movie) error
+	Get(id int64) (*data.Movie, error)
+	Update(movie *data.Movie) error
+	Delete(id int64) error
+}
+
+func (app *application) batchMovieHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Mode       string           `json:"mode"`
+		Operations []batchOperation `json:"operations"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Mode == "" {
+		input.Mode = batchModeAtomic
+	}
+
+	v := validator.New()
+
+	v.CheckCode(validator.In(input.Mode, batchModeAtomic, batchModeBestEffort), "mode", validator.CodeNotPermitted, "invalid batch mode", validator.Params{"allowed": []string{batchModeAtomic, batchModeBestEffort}})
+	v.CheckCode(len(input.Operations) >= 1, "operations", validator.CodeMinItems, "must contain at least 1 operation", validator.Params{"min": 1})
+	v.CheckCode(len(input.Operations) <= maxBatchOperations, "operations", validator.CodeMaxItems, fmt.Sprintf("must not contain more than %d operations", maxBatchOperations), validator.Params{"max": maxBatchOperations})
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// The genre vocabulary is loaded once for the whole batch.
+	genres, err := app.models.Genres.Vocabulary()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var store movieStore = &app.models.Movies
+	var tx *data.MovieTx
+
+	if input.Mode == batchModeAtomic {
+		tx, err = app.models.Movies.Begin()
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		// Rolling back after a commit is a no-op, so this is safe on every path.
+		defer tx.Rollback()
+
+		store = tx
+	}
+
+	results := make([]batchResult, len(input.Operations))
+	failed := false
+
+	for i, op := range input.Operations {
+		// In atomic mode each operation gets a savepoint, so that one failing (say on
+		// a duplicate external id) doesn't stop the rest from being checked.
+		if tx != nil {
+			if err := tx.Savepoint(); err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+
+		results[i], err = app.runBatchOperation(r, store, genres, i, op)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if results[i].Status == batchStatusFailed {
+			failed = true
+		}
+
+		if tx != nil {
+			if results[i].Status == batchStatusFailed {
+				err = tx.RollbackToSavepoint()
+			} else {
+				err = tx.ReleaseSavepoint()
+			}
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+	}
+
+	status := http.StatusOK
+
+	if tx != nil {
+		if failed {
+			// Nothing was applied, so don't report ids or versions that no longer exist.
+			for i := range results {
+				if results[i].Status == batchStatusOK {
+					results[i] = batchResult{Index: i, Op: results[i].Op, Status: batchStatusRolledBack}
+				}
+			}
+			status = http.StatusUnprocessableEntity
+		} else {
+			err = tx.Commit()
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+	}
+
+	// Refreshing the suggestions is cheap enough not to bother checking whether any
+	// operation actually changed a title.
+	app.suggestions.invalidate()
+
+	for _, result := range results {
+		switch {
+		case result.Status != batchStatusOK:
+		case result.Op == "delete":
+			app.similar.remove(result.ID)
+		default:
+			app.similar.upsert(result.movie)
+		}
+	}
+
+	err = app.writeJSON(w, status, envelope{"mode": input.Mode, "results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// runBatchOperation validates and runs one operation against store. Problems with the
+// operation itself are reported in the result; the error is only for server failures.
+func (app *application) runBatchOperation(r *http.Request, store movieStore, genres *data.GenreVocabulary, index int, op batchOperation) (batchResult, error) {
+
+	result := batchResult{Index: index, Op: op.Op, Status: batchStatusFailed}
+
+	v := validator.New()
+
+	v.CheckCode(validator.In(op.Op, "create", "update", "delete"), "op", validator.CodeNotPermitted, "invalid operation", validator.Params{"allowed": []string{"create", "update", "delete"}})
+	v.CheckCode(op.Op == "create" || op.ID > 0, "id", validator.CodeRequired, "must be provided", nil)
+	v.CheckCode(op.Op == "delete" || op.Movie != nil, "movie", validator.CodeRequired, "must be provided", nil)
+
+	if !v.Valid() {
+		result.Errors, result.Details = i18n.Localize(app.contextGetLocale(r), v)
+		return result, nil
+	}
+
+	var movie *data.Movie
+
+	switch op.Op {
+	case "create":
+		movie = &data.Movie{}
+		op.Movie.apply(movie)
+
+		if data.ValidateMovie(v, movie); !v.Valid() {
+			result.Errors, result.Details = i18n.Localize(app.contextGetLocale(r), v)
+			return result, nil
+		}
+
+		if movie.Genres = genres.Normalise(v, movie.Genres); !v.Valid() {
+			result.Errors, result.Details = i18n.Localize(app.contextGetLocale(r), v)
+			return result, nil
+		}
+
+		err := store.Insert(movie)
+		if err != nil {
+			switch {
+			case app.checkExternalIDConflict(v, err):
+				result.Errors, result.Details = i18n.Localize(app.contextGetLocale(r), v)
+				return result, nil
+			default:
+				return result, err
+			}
+		}
+
+	case "update":
+		var err error
+
+		movie, err = store.Get(op.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				result.Error = app.message(r, i18n.MsgNotFound, nil)
+				return result, nil
+			default:
+				return result, err
+			}
+		}
+
+		if op.Version != nil && *op.Version != movie.Version {
+			result.Error = app.message(r, i18n.MsgEditConflict, nil)
+			return result, nil
+		}
+
+		op.Movie.apply(movie)
+
+		if data.ValidateMovie(v, movie); !v.Valid() {
+			result.Errors, result.Details = i18n.Localize(app.contextGetLocale(r), v)
+			return result, nil
+		}
+
+		if op.Movie.Genres != nil {
+			if movie.Genres = genres.Normalise(v, movie.Genres); !v.Valid() {
+				result.Errors, result.Details = i18n.Localize(app.contextGetLocale(r), v)
+				return result, nil
+			}
+		}
+
+		err = store.Update(movie)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrEditConflict):
+				result.Error = app.message(r, i18n.MsgEditConflict, nil)
+				return result, nil
+			case app.checkExternalIDConflict(v, err):
+				result.Errors, result.Details = i18n.Localize(app.contextGetLocale(r), v)
+				return result, nil
+			default:
+				return result, err
+			}
+		}
+
+	case "delete":
+		err := store.Delete(op.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				result.Error = app.message(r, i18n.MsgNotFound, nil)
+				return result, nil
+			default:
+				return result, err
+			}
+		}
+
+		result.Status = batchStatusOK
+		result.ID = op.ID
+		return result, nil
+	}
+
+	result.Status = batchStatusOK
+	result.ID = movie.ID
+	result.Version = movie.Version
+	result.movie = movie
+	return result, nil
+}
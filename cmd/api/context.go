@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/goddhi/zeliz-movie/internal/i18n"
+)
+
+// custom contextKey type so our keys can't collide with keys set by other packages
+type contextKey string
+
+const (
+	localeContextKey = contextKey("locale")
+	userContextKey   = contextKey("user")
+)
+
+// contextSetLocale returns a copy of the request with the negotiated locale added to
+// its context.
+func (app *application) contextSetLocale(r *http.Request, locale string) *http.Request {
+	ctx := context.WithValue(r.Context(), localeContextKey, locale)
+	return r.WithContext(ctx)
+}
+
+// contextGetLocale retrieves the locale from the request context, falling back to the
+// default locale for requests that didn't pass through negotiateLanguage.
+func (app *application) contextGetLocale(r *http.Request) string {
+	locale, ok := r.Context().Value(localeContextKey).(string)
+	if !ok {
+		return i18n.DefaultLocale
+	}
+	return locale
+}
+
+// contextSetUser returns a copy of the request with the user added to its context.
+func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	return r.WithContext(ctx)
+}
+
+// contextGetUser retrieves the user set by the authenticate middleware. Every request
+// goes through authenticate, so a missing value is a bug and we panic.
+func (app *application) contextGetUser(r *http.Request) *data.User {
+	user, ok := r.Context().Value(userContextKey).(*data.User)
+	if !ok {
+		panic("missing user value in request context")
+	}
+	return user
+}
@@ -1,54 +1,141 @@
-package main
-
-import (
-	"fmt"
-	"net/http"
-
-)
-
-
-func (app *application) logError(r *http.Request, err error) {
-	app.logger.Println(err)
-}
-
-func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
-		env := envelope{"error": message}
-
-		err := app.writeJSON(w, status, env, nil)
-		if err != nil {
-			app.logError(r, err)
-			w.WriteHeader(500)
-		}
-}
-
-
-func (app *application) serverErrorResponse(w http.ResponseWriter, r * http.Request, err error) {
-	app.logError(r, err)
-	message :=  "the server encountered a problem and could not process your request"
-	app.errorResponse(w, r, http.StatusInternalServerError, message)
-}
-
-func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
-	message := "the requested resource could not be found"
-	app.errorResponse(w, r, http.StatusNotFound, message)
-}
-
-func (app *application) methodNotAllowedRespose(w http.ResponseWriter, r *http.Request) {
-	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
-	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
-}
-
-
-
-func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
-} 
-
-func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
-}
-
-func (app *application) EditConflictResponse(w http.ResponseWriter, r *http.Request) {
-	message := "unable to update the record due to an edit conflic, please try again"
-	app.errorResponse(w, r, http.StatusConflict, message)
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/goddhi/zeliz-movie/internal/i18n"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+
+func (app *application) logError(r *http.Request, err error) {
+	app.logger.Println(err)
+}
+
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
+		env := envelope{"error": message}
+
+		err := app.writeJSON(w, status, env, nil)
+		if err != nil {
+			app.logError(r, err)
+			w.WriteHeader(500)
+		}
+}
+
+
+// message looks up the catalogue message for code in the request's negotiated locale.
+func (app *application) message(r *http.Request, code string, params validator.Params) string {
+	return i18n.Message(app.contextGetLocale(r), code, params)
+}
+
+
+func (app *application) serverErrorResponse(w http.ResponseWriter, r * http.Request, err error) {
+	app.logError(r, err)
+	message :=  app.message(r, i18n.MsgServerError, nil)
+	app.errorResponse(w, r, http.StatusInternalServerError, message)
+}
+
+func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, i18n.MsgNotFound, nil)
+	app.errorResponse(w, r, http.StatusNotFound, message)
+}
+
+func (app *application) methodNotAllowedRespose(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, i18n.MsgMethodNotAllowed, validator.Params{"method": r.Method})
+	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
+}
+
+
+
+// badRequestResponse describes what was wrong with the request body in the client's
+// language. Errors that don't come from readJSON() get a generic message, since their
+// text is only ever in English.
+func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var bodyErr *bodyError
+	var maxBytesErr *http.MaxBytesError
+
+	var message string
+
+	switch {
+	case errors.As(err, &bodyErr):
+		message = app.message(r, bodyErr.code, bodyErr.params)
+	case errors.As(err, &maxBytesErr):
+		message = app.message(r, i18n.MsgBodyTooLarge, validator.Params{"max": maxBytesErr.Limit})
+	default:
+		message = app.message(r, i18n.MsgBadRequest, nil)
+	}
+
+	app.errorResponse(w, r, http.StatusBadRequest, message)
+}
+
+// failedValidationResponse sends the first message for each field under "error", as it
+// always has, plus every failed check with its code and params under "details". The
+// messages are translated into the request's negotiated locale.
+func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator) {
+	errors, details := i18n.Localize(app.contextGetLocale(r), v)
+	env := envelope{"error": errors, "details": details}
+
+	err := app.writeJSON(w, http.StatusUnprocessableEntity, env, nil)
+	if err != nil {
+		app.logError(r, err)
+		w.WriteHeader(500)
+	}
+}
+
+func (app *application) EditConflictResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, i18n.MsgEditConflict, nil)
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) idempotencyKeyReusedResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, i18n.MsgIdempotencyKeyReused, nil)
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, message)
+}
+
+func (app *application) idempotencyKeyInProgressResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, i18n.MsgIdempotencyKeyInProgress, nil)
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) genreInUseResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, i18n.MsgGenreInUse, nil)
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, i18n.MsgInvalidCredentials, nil)
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+
+	message := app.message(r, i18n.MsgInvalidAuthenticationToken, nil)
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, i18n.MsgAuthenticationRequired, nil)
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) ratingExistsResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, i18n.MsgRatingExists, nil)
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, i18n.MsgNotPermitted, nil)
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) reviewExistsResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, i18n.MsgReviewExists, nil)
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.message(r, i18n.MsgRateLimitExceeded, nil)
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
 }
\ No newline at end of file
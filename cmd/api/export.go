@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+// exportFormats maps the format names accepted in the format query string parameter to
+// their media types.
+var exportFormats = map[string]string{
+	"csv":    "text/csv",
+	"ndjson": "application/x-ndjson",
+	"json":   "application/json",
+}
+
+// exportWriteTimeout replaces the server's WriteTimeout for exports, which can take a
+// while to stream for a large catalogue.
+const exportWriteTimeout = 10 * time.Minute
+
+func (app *application) exportMoviesHandler(w http.ResponseWriter, r *http.Request) {
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	filter := app.readMovieFilter(qs, v)
+
+	format := app.readString(qs, "format", "")
+	if format == "" {
+		format = negotiateExportFormat(r.Header.Get("Accept"))
+	}
+
+	v.CheckCode(exportFormats[format] != "", "format", validator.CodeNotPermitted, "invalid export format", validator.Params{"allowed": []string{"csv", "ndjson", "json"}})
+
+	runtimeFormat := app.readRuntimeFormat(r, v)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err := app.resolveGenreFilter(&filter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	if err != nil {
+		app.logError(r, err)
+	}
+
+	exporter := newMovieExporter(w, format, runtimeFormat)
+
+	err = app.models.Movies.Export(r.Context(), filter, exporter.write)
+	if err != nil {
+		// If nothing has been sent yet we can still report the error properly.
+		// Otherwise the best we can do is log it and cut the response short.
+		if !exporter.started {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.logError(r, err)
+		return
+	}
+
+	err = exporter.finish()
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// negotiateExportFormat picks an export format from an Accept header, preferring the
+// media types with the highest q-value. It defaults to JSON.
+func negotiateExportFormat(accept string) string {
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+
+	var ranges []mediaRange
+
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if f, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					q = f
+				}
+			}
+		}
+
+		ranges = append(ranges, mediaRange{mediaType, q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, mr := range ranges {
+		if mr.q <= 0 {
+			continue
+		}
+		for format, mediaType := range exportFormats {
+			if mr.mediaType == mediaType {
+				return format
+			}
+		}
+	}
+
+	return "json"
+}
+
+// movieExporter writes movies to the response one at a time in the chosen format. The
+// headers are only sent with the first movie (or by finish), so that an error from the
+// database before then can still be turned into a proper error response.
+type movieExporter struct {
+	w             http.ResponseWriter
+	format        string
+	runtimeFormat data.RuntimeFormat
+	started       bool
+	count         int
+
+	csv  *csv.Writer
+	json *json.Encoder
+}
+
+func newMovieExporter(w http.ResponseWriter, format string, runtimeFormat data.RuntimeFormat) *movieExporter {
+	return &movieExporter{w: w, format: format, runtimeFormat: runtimeFormat}
+}
+
+func (e *movieExporter) start() error {
+	e.started = true
+
+	e.w.Header().Set("Content-Type", exportFormats[e.format])
+	e.w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="movies.%s"`, e.format))
+	e.w.WriteHeader(http.StatusOK)
+
+	switch e.format {
+	case "csv":
+		e.csv = csv.NewWriter(e.w)
+		return e.csv.Write([]string{"id", "title", "year", "runtime", "genres", "version"})
+	case "ndjson":
+		e.json = json.NewEncoder(e.w)
+	case "json":
+		e.json = json.NewEncoder(e.w)
+		_, err := e.w.Write([]byte("{\"movies\":[\n"))
+		return err
+	}
+
+	return nil
+}
+
+func (e *movieExporter) write(movie *data.Movie) error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	movie.SetRuntimeFormat(e.runtimeFormat)
+
+	var err error
+
+	switch e.format {
+	case "csv":
+		err = e.csv.Write([]string{
+			strconv.FormatInt(movie.ID, 10),
+			movie.Title,
+			strconv.Itoa(int(movie.Year)),
+			fmt.Sprint(movie.Runtime.Format(e.runtimeFormat)),
+			strings.Join(movie.Genres, "|"),
+			strconv.Itoa(int(movie.Version)),
+		})
+	case "json":
+		if e.count > 0 {
+			if _, err = e.w.Write([]byte(",")); err != nil {
+				return err
+			}
+		}
+		err = e.json.Encode(movie)
+	case "ndjson":
+		err = e.json.Encode(movie)
+	}
+
+	e.count++
+
+	return err
+}
+
+func (e *movieExporter) finish() error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	switch e.format {
+	case "csv":
+		e.csv.Flush()
+		return e.csv.Error()
+	case "json":
+		_, err := e.w.Write([]byte("]}\n"))
+		return err
+	}
+
+	return nil
+}
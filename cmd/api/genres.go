@@ -0,0 +1,294 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/goddhi/zeliz-movie/internal/filter"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// normaliseGenres maps the genres a client sent to their slugs in the genre vocabulary,
+// recording an error in v for any that aren't known.
+func (app *application) normaliseGenres(v *validator.Validator, genres []string) ([]string, error) {
+	vocab, err := app.models.Genres.Vocabulary()
+	if err != nil {
+		return nil, err
+	}
+
+	return vocab.Normalise(v, genres), nil
+}
+
+// resolveGenreFilter maps the genres in a movie filter to slugs, both the ?genres= list
+// and any genres HAS "..." comparisons in the expression. Filters aren't validated
+// against the vocabulary: an unknown genre is reduced to its key and simply matches
+// nothing.
+func (app *application) resolveGenreFilter(f *data.MovieFilter) error {
+	var comparisons []*filter.Comparison
+
+	if f.Expression != nil {
+		filter.Inspect(f.Expression, func(c *filter.Comparison) {
+			if c.Field == "genres" {
+				comparisons = append(comparisons, c)
+			}
+		})
+	}
+
+	if len(f.Genres) == 0 && len(comparisons) == 0 {
+		return nil
+	}
+
+	vocab, err := app.models.Genres.Vocabulary()
+	if err != nil {
+		return err
+	}
+
+	resolve := func(genre string) string {
+		slug, ok := vocab.Resolve(genre)
+		if !ok {
+			slug = data.GenreKey(genre)
+		}
+		return slug
+	}
+
+	slugs := make([]string, len(f.Genres))
+	for i, genre := range f.Genres {
+		slugs[i] = resolve(genre)
+	}
+	f.Genres = slugs
+
+	for _, c := range comparisons {
+		c.Value = resolve(c.Value.(string))
+	}
+
+	return nil
+}
+
+func (app *application) listGenresHandler(w http.ResponseWriter, r *http.Request) {
+
+	genres, err := app.models.Genres.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"genres": genres}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createGenreHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Slug    string   `json:"slug"`
+		Name    string   `json:"name"`
+		Aliases []string `json:"aliases"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	genre := &data.Genre{
+		Slug:    input.Slug,
+		Name:    input.Name,
+		Aliases: input.Aliases,
+	}
+
+	v := validator.New()
+
+	if data.ValidateGenre(v, genre); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Genres.Insert(genre)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateGenre):
+			v.AddCodedError("slug", validator.CodeTaken, "the slug or one of the aliases is already used by a genre", nil)
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/genres/%s", genre.Slug))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"genre": genre}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showGenreHandler(w http.ResponseWriter, r *http.Request) {
+
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+
+	genre, err := app.models.Genres.Get(slug)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"genre": genre}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateGenreHandler changes a genre's display name and/or replaces its aliases. The
+// slug can't be changed here; merge the genre into a new one instead.
+func (app *application) updateGenreHandler(w http.ResponseWriter, r *http.Request) {
+
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+
+	genre, err := app.models.Genres.Get(slug)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name    *string   `json:"name"`
+		Aliases *[]string `json:"aliases"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		genre.Name = *input.Name
+	}
+
+	if input.Aliases != nil {
+		genre.Aliases = *input.Aliases
+	}
+
+	v := validator.New()
+
+	if data.ValidateGenre(v, genre); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Genres.Update(genre)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.EditConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateGenre):
+			v.AddCodedError("aliases", validator.CodeTaken, "one of the aliases is already used by another genre", nil)
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"genre": genre}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteGenreHandler(w http.ResponseWriter, r *http.Request) {
+
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+
+	err := app.models.Genres.Delete(slug)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrGenreInUse):
+			app.genreInUseResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "genre successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// mergeGenreHandler folds the genre in the URL into another one, e.g.
+// POST /v1/genres/sci-fi/merge {"into": "science-fiction"}. Movies are re-tagged and the
+// old slug becomes an alias, so clients still sending it keep working.
+func (app *application) mergeGenreHandler(w http.ResponseWriter, r *http.Request) {
+
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+
+	var input struct {
+		Into string `json:"into"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.CheckCode(input.Into != "", "into", validator.CodeRequired, "must be provided", nil)
+	v.CheckCode(input.Into != slug, "into", validator.CodeInvalid, "must be a different genre", nil)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	moviesChanged, err := app.models.Genres.Merge(slug, input.Into)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// The merge re-tags movies straight in the database, so the caches holding them
+	// are rebuilt rather than updated.
+	if moviesChanged > 0 {
+		app.suggestions.invalidate()
+		app.similar.invalidate()
+	}
+
+	genre, err := app.models.Genres.Get(input.Into)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"genre": genre, "movies_updated": moviesChanged}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -1,173 +1,306 @@
-package main
-
-import (
-	"encoding/json"
-	"errors"
-	"net/http"
-	"strconv"
-	"fmt"
-	"io"
-	"strings"
-	"net/url"
-
-	"github.com/julienschmidt/httprouter"
-	"github.com/goddhi/zeliz-movie/internal/validator"
-
-
-)
-
-
-// Retrieve the "id" URL parameter from the current request context, then convert it to
-// an integer and return it. If the operation isn't successful, return 0 and an error.
-func (app *application) readIDParam(r *http.Request) (int64, error) {
-
-	params := httprouter.ParamsFromContext(r.Context())
-
-	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
-	if err != nil || id < 1 {
-		return 0, errors.New("invalid id parameter")
-	}
-	return id, nil
-}
-
-// implementing envelope responses
-type envelope map[string]interface{}
-
-//  writeJSON helper method
-func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
-	// Use http.MaxBytesReader() to limit the size of the request body to 1MB.
-
-	js, err := json.MarshalIndent(data, "", "\t") // Here we use no line prefix ("") and tab indents ("\t") for each element.
-	if err != nil {
-		return err
-	}
-
-	// Append a newline to make it easier to view in terminal applications.
-	js = append(js, '\n')
-
-	
-// We loop through the header map and add each header to the http.ResponseWriter header map
-	for key, value := range headers {
-		w.Header()[key] = value
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	w.Write(js)
-
-
-	return nil
-
-}
-
-
-
-
-func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
-	maxBytes := 1_048_576
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
-
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()  // This means that if the JSON from the client now includes any
-	// field which cannot be mapped to the target destination, the decoder will return
-	// an error instead of just ignoring the field.
-	err := dec.Decode(dst)	// // Decode the request body to the destination.
-	if err != nil {
-	// If there is an error during decoding, start the triage...
-	var syntaxError *json.SyntaxError
-	var unmarshalTypeError *json.UnmarshalTypeError
-	var invalidUnmarshalError *json.InvalidUnmarshalError
-	switch {
-
-	case errors.As(err, &syntaxError):
-	return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
-
-	case errors.Is(err, io.ErrUnexpectedEOF):
-	return errors.New("body contains badly-formed JSON")
-
-	case errors.As(err, &unmarshalTypeError):
-	if unmarshalTypeError.Field != "" {
-	return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
-	}
-	return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
-
-	case errors.Is(err, io.EOF):
-	return errors.New("body must not be empty")
-
-
-	case strings.HasPrefix(err.Error(), "json: unknow field"):
-		fileName := strings.TrimPrefix(err.Error(), "json: unknown field")
-		return fmt.Errorf("body contains unknown key %s", fileName)
-
-	case err.Error() == "http: request body too large":
-		return fmt.Errorf("body must be larger than %d bytes", maxBytes)
- 	
-	
-	case errors.As(err, &invalidUnmarshalError):
-		panic(err)  // this error is likely to occur in the development stage due to not assiging pointers to the struct 
-		// For anything else, return the error message as-is.	
-	
-	default:
-	return err
-
-	}
-}
-
-	err = dec.Decode(&struct{}{})
-	if err != io.EOF {
-		return errors.New("body must only contain a single JSON value")
-	}
-return nil
-}
-
-// The readString() helper returns a string value from the query string, or the provided
-// default value if no matching key could be found
-func (app *application) readString(qs url.Values, key string, defaultValue string) string {
-	
-	s := qs.Get(key) // extacts the value for a given key from the query string, if no key exists this will return the enoty string ""
-
-	if s == "" {
-		return defaultValue
-	}
-
-	return s 
-}
-
-
-// The readCSV() helper reads a string value from the query string and then splits it
-// into a slice on the comma character. If no matching key could be found, it returns
-// the provided default value.
-
-func (app *application) readCSV(qs url.Values, key string, defaultValue []string) []string {
-	
-	csv := qs.Get(key)
-
-	if csv == "" {
-		return defaultValue
-	}
-
-	// Otherwise parse the value into a []string slice and return it.
-	return strings.Split(csv, ",")
-
-} 
-
-// The readInt() helper reads a string value from the query string and converts it to an
-// integer before returning. If no matching key could be found it returns the provided
-// default value. If the value couldn't be converted to an integer, then we record an
-// error message in the provided Validator instance.
-func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
-	
-	s := qs.Get(key)
-
-	if s == "" {
-		return defaultValue
-	}
-
-	i, err := strconv.Atoi(s)
-	if err != nil {
-		v.AddError(key, "must be integer vallue")
-		return defaultValue
-	}
-
-	return i
-}
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"fmt"
+	"io"
+	"strings"
+	"net/url"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/goddhi/zeliz-movie/internal/filter"
+	"github.com/goddhi/zeliz-movie/internal/i18n"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+
+
+)
+
+
+// Retrieve the "id" URL parameter from the current request context, then convert it to
+// an integer and return it. If the operation isn't successful, return 0 and an error.
+func (app *application) readIDParam(r *http.Request) (int64, error) {
+
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid id parameter")
+	}
+	return id, nil
+}
+
+// implementing envelope responses
+type envelope map[string]interface{}
+
+//  writeJSON helper method
+func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
+	// Use http.MaxBytesReader() to limit the size of the request body to 1MB.
+
+	js, err := json.MarshalIndent(data, "", "\t") // Here we use no line prefix ("") and tab indents ("\t") for each element.
+	if err != nil {
+		return err
+	}
+
+	// Append a newline to make it easier to view in terminal applications.
+	js = append(js, '\n')
+
+	
+// We loop through the header map and add each header to the http.ResponseWriter header map
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+
+
+	return nil
+
+}
+
+
+
+
+// bodyError is returned by readJSON() when the request body can't be decoded. It
+// carries the catalogue code for the problem so badRequestResponse() can send it in the
+// client's language.
+type bodyError struct {
+	code   string
+	params validator.Params
+}
+
+func (e *bodyError) Error() string {
+	return i18n.Message(i18n.DefaultLocale, e.code, e.params)
+}
+
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	maxBytes := 1_048_576
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()  // This means that if the JSON from the client now includes any
+	// field which cannot be mapped to the target destination, the decoder will return
+	// an error instead of just ignoring the field.
+	err := dec.Decode(dst)	// // Decode the request body to the destination.
+	if err != nil {
+	// If there is an error during decoding, start the triage...
+	var syntaxError *json.SyntaxError
+	var unmarshalTypeError *json.UnmarshalTypeError
+	var invalidUnmarshalError *json.InvalidUnmarshalError
+	switch {
+
+	case errors.As(err, &syntaxError):
+	return &bodyError{i18n.MsgBodyMalformedAt, validator.Params{"offset": syntaxError.Offset}}
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+	return &bodyError{i18n.MsgBodyMalformed, nil}
+
+	case errors.As(err, &unmarshalTypeError):
+	if unmarshalTypeError.Field != "" {
+	return &bodyError{i18n.MsgBodyWrongType, validator.Params{"field": strconv.Quote(unmarshalTypeError.Field)}}
+	}
+	return &bodyError{i18n.MsgBodyWrongTypeAt, validator.Params{"offset": unmarshalTypeError.Offset}}
+
+	case errors.Is(err, io.EOF):
+	return &bodyError{i18n.MsgBodyEmpty, nil}
+
+
+	case strings.HasPrefix(err.Error(), "json: unknow field"):
+		fileName := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		return &bodyError{i18n.MsgBodyUnknownKey, validator.Params{"key": fileName}}
+
+	case err.Error() == "http: request body too large":
+		return &bodyError{i18n.MsgBodyTooLarge, validator.Params{"max": maxBytes}}
+ 	
+	
+	case errors.As(err, &invalidUnmarshalError):
+		panic(err)  // this error is likely to occur in the development stage due to not assiging pointers to the struct 
+		// For anything else, return the error message as-is.	
+	
+	default:
+	return err
+
+	}
+}
+
+	err = dec.Decode(&struct{}{})
+	if err != io.EOF {
+		return &bodyError{i18n.MsgBodyMultipleValues, nil}
+	}
+return nil
+}
+
+// The readString() helper returns a string value from the query string, or the provided
+// default value if no matching key could be found
+func (app *application) readString(qs url.Values, key string, defaultValue string) string {
+	
+	s := qs.Get(key) // extacts the value for a given key from the query string, if no key exists this will return the enoty string ""
+
+	if s == "" {
+		return defaultValue
+	}
+
+	return s 
+}
+
+
+// The readCSV() helper reads a string value from the query string and then splits it
+// into a slice on the comma character. If no matching key could be found, it returns
+// the provided default value.
+
+func (app *application) readCSV(qs url.Values, key string, defaultValue []string) []string {
+	
+	csv := qs.Get(key)
+
+	if csv == "" {
+		return defaultValue
+	}
+
+	// Otherwise parse the value into a []string slice and return it.
+	return strings.Split(csv, ",")
+
+} 
+
+// The readInt() helper reads a string value from the query string and converts it to an
+// integer before returning. If no matching key could be found it returns the provided
+// default value. If the value couldn't be converted to an integer, then we record an
+// error message in the provided Validator instance.
+func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
+	
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddCodedError(key, validator.CodeNotInteger, "must be integer vallue", nil)
+		return defaultValue
+	}
+
+	return i
+}
+
+// The readFloat() helper reads a string value from the query string and converts it to
+// a float before returning. If no matching key could be found it returns the provided
+// default value. If the value couldn't be converted, then we record an error message in
+// the provided Validator instance.
+func (app *application) readFloat(qs url.Values, key string, defaultValue float64, v *validator.Validator) float64 {
+
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		v.AddCodedError(key, validator.CodeNotNumber, "must be a number", nil)
+		return defaultValue
+	}
+
+	return f
+}
+
+// The readTime() helper reads a timestamp from the query string, in any format that
+// filter.ParseTimestamp accepts. If no matching key could be found it returns the zero
+// time. If the value couldn't be parsed, then we record an error message in the
+// provided Validator instance.
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) time.Time {
+
+	s := qs.Get(key)
+
+	if s == "" {
+		return time.Time{}
+	}
+
+	t, ok := filter.ParseTimestamp(s)
+	if ok {
+		return t
+	}
+
+	v.AddCodedError(key, validator.CodeNotTimestamp, "must be an RFC 3339 timestamp or a YYYY-MM-DD date", nil)
+	return time.Time{}
+}
+
+// The readMovieFilter() helper reads the movie filters shared by the listing and the
+// export from the query string, including a filter expression, recording any errors in
+// the provided Validator instance. The genres are returned as given; see
+// resolveGenreFilter.
+func (app *application) readMovieFilter(qs url.Values, v *validator.Validator) data.MovieFilter {
+
+	movieFilter := data.MovieFilter{
+		Title:         app.readString(qs, "title", ""),
+		Match:         app.readString(qs, "match", data.MatchExact),
+		Similarity:    app.readFloat(qs, "similarity", data.DefaultSimilarity, v),
+		Genres:        app.readCSV(qs, "genres", []string{}),
+		GenreMatch:    app.readString(qs, "genre_match", data.GenreMatchAll),
+		YearMin:       app.readInt(qs, "year_min", 0, v),
+		YearMax:       app.readInt(qs, "year_max", 0, v),
+		RuntimeMin:    app.readInt(qs, "runtime_min", 0, v),
+		RuntimeMax:    app.readInt(qs, "runtime_max", 0, v),
+		CreatedAfter:  app.readTime(qs, "created_after", v),
+		CreatedBefore: app.readTime(qs, "created_before", v),
+	}
+
+	data.ValidateMovieFilter(v, movieFilter)
+
+	if expression := qs.Get("filter"); expression != "" {
+		if len(expression) > filter.MaxLength {
+			v.AddCodedError("filter", validator.CodeMaxBytes, fmt.Sprintf("must not be more than %d bytes long", filter.MaxLength), validator.Params{"max": filter.MaxLength})
+			return movieFilter
+		}
+
+		node, err := filter.Parse(expression, data.MovieFilterFields)
+		if err != nil {
+			var filterErr *filter.Error
+			if errors.As(err, &filterErr) {
+				v.AddCodedError("filter", filterErr.Code, filterErr.Error(), filterErr.Params())
+			}
+			return movieFilter
+		}
+
+		movieFilter.Expression = node
+	}
+
+	return movieFilter
+}
+
+// The readRuntimeFormat() helper returns the runtime format the client asked for, either
+// with the runtime_format query string parameter or the Runtime-Format header (the query
+// string wins). It defaults to "N mins", and records an error in the provided Validator
+// instance if the format isn't one we support.
+func (app *application) readRuntimeFormat(r *http.Request, v *validator.Validator) data.RuntimeFormat {
+
+	format := app.readString(r.URL.Query(), "runtime_format", r.Header.Get("Runtime-Format"))
+
+	if format == "" {
+		return data.RuntimeFormatMins
+	}
+
+	v.CheckCode(validator.In(format, data.RuntimeFormats...), "runtime_format", validator.CodeNotPermitted, "invalid runtime format", validator.Params{"allowed": data.RuntimeFormats})
+
+	return data.RuntimeFormat(format)
+}
+
+// The readMovieFields() helper returns the movie fields the client asked for with the
+// fields query string parameter, e.g. ?fields=id,title,year, or nil if it wasn't given.
+// Unknown and repeated fields are recorded in the provided Validator instance.
+func (app *application) readMovieFields(qs url.Values, v *validator.Validator) []string {
+
+	fields := app.readCSV(qs, "fields", nil)
+
+	for _, field := range fields {
+		v.CheckCode(validator.In(field, data.MovieFieldNames...), "fields", validator.CodeNotPermitted, "invalid field", validator.Params{"allowed": data.MovieFieldNames})
+	}
+	v.CheckCode(validator.Unique(fields), "fields", validator.CodeDuplicate, "must not contain duplicate values", nil)
+
+	return fields
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+// includer loads one kind of related data onto a page of movies, with a single query
+// for the whole page however many movies are on it.
+type includer func(app *application, movies []*data.Movie) error
+
+// movieIncludes are the related resources that can be embedded in movies with
+// ?include=, e.g. ?include=credits,rating_summary. Each endpoint says which of them it
+// supports when reading the parameter.
+var movieIncludes = map[string]includer{
+	"credits":        includeCredits,
+	"external_ids":   includeExternalIDs,
+	"rating_summary": includeRatingSummaries,
+}
+
+// movieIncludeNames returns the names of every include, in a stable order.
+func movieIncludeNames() []string {
+	names := make([]string, 0, len(movieIncludes))
+	for name := range movieIncludes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// The readIncludes() helper returns the related resources the client asked for with the
+// include query string parameter, recording an error in the provided Validator instance
+// for any the endpoint doesn't allow or that are repeated.
+func (app *application) readIncludes(qs url.Values, v *validator.Validator, allowed []string) []string {
+
+	include := app.readCSV(qs, "include", []string{})
+
+	for _, name := range include {
+		v.CheckCode(validator.In(name, allowed...), "include", validator.CodeNotPermitted, "invalid include value", validator.Params{"allowed": allowed})
+	}
+	v.CheckCode(validator.Unique(include), "include", validator.CodeDuplicate, "must not contain duplicate values", nil)
+
+	return include
+}
+
+// loadIncludes loads each of the named related resources onto the movies.
+func (app *application) loadIncludes(movies []*data.Movie, include []string) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	for _, name := range include {
+		if err := movieIncludes[name](app, movies); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// movieIDs returns the ids of the movies.
+func movieIDs(movies []*data.Movie) []int64 {
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+	return ids
+}
+
+func includeCredits(app *application, movies []*data.Movie) error {
+	credits, err := app.models.Credits.GetForMovies(movieIDs(movies))
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		movie.Credits = credits[movie.ID]
+	}
+
+	return nil
+}
+
+// includeExternalIDs loads the external ids of the movies that don't already have them.
+// They're read along with a movie unless ?fields= leaves them out, so this is only
+// needed to embed them alongside a sparse fieldset.
+func includeExternalIDs(app *application, movies []*data.Movie) error {
+	var missing []*data.Movie
+
+	for _, movie := range movies {
+		if movie.ExternalIDs == nil {
+			missing = append(missing, movie)
+		}
+	}
+
+	return app.models.Movies.LoadExternalIDs(missing)
+}
+
+func includeRatingSummaries(app *application, movies []*data.Movie) error {
+	summaries, err := app.models.Ratings.SummariesForMovies(movieIDs(movies))
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		movie.RatingSummary = summaries[movie.ID]
+	}
+
+	return nil
+}
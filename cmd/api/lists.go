@@ -0,0 +1,404 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// listListsHandler returns a page of a user's lists. With no user_id it's the
+// authenticated user's own lists, private ones included; otherwise only that user's
+// public lists are shown, unless they're your own.
+func (app *application) listListsHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		UserID int
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.UserID = app.readInt(qs, "user_id", 0, v)
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-updated_at")
+	input.Filters.SortStatelist = []string{"created_at", "updated_at", "name", "id", "-created_at", "-updated_at", "-name", "-id"}
+
+	v.CheckCode(input.UserID >= 0, "user_id", validator.CodeMinValue, "must not be negative", validator.Params{"min": 0})
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	userID := int64(input.UserID)
+	if userID == 0 {
+		if user.IsAnonymous() {
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
+		userID = user.ID
+	}
+
+	lists, err := app.models.Lists.GetAllForUser(userID, !user.IsAnonymous() && user.ID == userID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"lists": lists}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createListHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Visibility  string `json:"visibility"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Lists are private unless asked otherwise.
+	if input.Visibility == "" {
+		input.Visibility = data.ListPrivate
+	}
+
+	list := &data.List{
+		UserID:      app.contextGetUser(r).ID,
+		Name:        input.Name,
+		Description: input.Description,
+		Visibility:  input.Visibility,
+	}
+
+	v := validator.New()
+
+	if data.ValidateList(v, list); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Lists.Insert(list)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/lists/%d", list.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"list": list, "items": []*data.ListItem{}}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readList fetches the list in the URL, provided the request's user can see it. Private
+// lists are a 404 to everyone but their owner. If owner is set the user must also own
+// the list, and anyone else looking at a public list gets a 403. If it returns nil a
+// response has already been sent.
+func (app *application) readList(w http.ResponseWriter, r *http.Request, owner bool) *data.List {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil
+	}
+
+	list, err := app.models.Lists.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+
+	user := app.contextGetUser(r)
+
+	if !list.VisibleTo(user) {
+		app.notFoundResponse(w, r)
+		return nil
+	}
+
+	if owner && list.UserID != user.ID {
+		app.notPermittedResponse(w, r)
+		return nil
+	}
+
+	return list
+}
+
+// writeListResponse sends a list with its items, re-reading both so the response shows
+// the list as it is now.
+func (app *application) writeListResponse(w http.ResponseWriter, r *http.Request, status int, id int64) {
+	list, err := app.models.Lists.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	items, err := app.models.Lists.Items(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, status, envelope{"list": list, "items": items}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showListHandler(w http.ResponseWriter, r *http.Request) {
+
+	list := app.readList(w, r, false)
+	if list == nil {
+		return
+	}
+
+	app.writeListResponse(w, r, http.StatusOK, list.ID)
+}
+
+func (app *application) updateListHandler(w http.ResponseWriter, r *http.Request) {
+
+	list := app.readList(w, r, true)
+	if list == nil {
+		return
+	}
+
+	var input struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		Visibility  *string `json:"visibility"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		list.Name = *input.Name
+	}
+	if input.Description != nil {
+		list.Description = *input.Description
+	}
+	if input.Visibility != nil {
+		list.Visibility = *input.Visibility
+	}
+
+	v := validator.New()
+
+	if data.ValidateList(v, list); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Lists.Update(list)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.EditConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"list": list}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteListHandler(w http.ResponseWriter, r *http.Request) {
+
+	list := app.readList(w, r, true)
+	if list == nil {
+		return
+	}
+
+	err := app.models.Lists.Delete(list.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "list successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// addListItemHandler puts a movie on a list, at the end unless a position is given.
+func (app *application) addListItemHandler(w http.ResponseWriter, r *http.Request) {
+
+	list := app.readList(w, r, true)
+	if list == nil {
+		return
+	}
+
+	var input struct {
+		MovieID  int64 `json:"movie_id"`
+		Position int32 `json:"position"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.CheckCode(input.MovieID > 0, "movie_id", validator.CodeRequired, "must be provided", nil)
+	v.CheckCode(input.Position >= 0, "position", validator.CodeMinValue, "must not be negative", validator.Params{"min": 0})
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	_, err = app.models.Movies.Get(input.MovieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddCodedError("movie_id", validator.CodeDoesNotExist, "does not exist", validator.Params{"id": input.MovieID})
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Lists.AddItem(list.ID, input.MovieID, input.Position)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateListItem):
+			v.AddCodedError("movie_id", validator.CodeDuplicate, "is already on the list", nil)
+			app.failedValidationResponse(w, r, v)
+		case errors.Is(err, data.ErrListFull):
+			v.AddCodedError("movie_id", validator.CodeMaxItems, fmt.Sprintf("the list must not contain more than %d movies", data.MaxListItems), validator.Params{"max": data.MaxListItems})
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeListResponse(w, r, http.StatusCreated, list.ID)
+}
+
+// readListItemParam returns the movie_id URL parameter, or 0 if it isn't a valid ID.
+func (app *application) readListItemParam(r *http.Request) int64 {
+	id, err := strconv.ParseInt(httprouter.ParamsFromContext(r.Context()).ByName("movie_id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0
+	}
+	return id
+}
+
+// moveListItemHandler reorders a list by moving one of its movies to a new position.
+func (app *application) moveListItemHandler(w http.ResponseWriter, r *http.Request) {
+
+	list := app.readList(w, r, true)
+	if list == nil {
+		return
+	}
+
+	movieID := app.readListItemParam(r)
+	if movieID == 0 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Position *int32 `json:"position"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.CheckCode(input.Position != nil, "position", validator.CodeRequired, "must be provided", nil)
+	v.CheckCode(input.Position == nil || *input.Position >= 1, "position", validator.CodeMinValue, "must be at least 1", validator.Params{"min": 1})
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Lists.MoveItem(list.ID, movieID, *input.Position)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeListResponse(w, r, http.StatusOK, list.ID)
+}
+
+func (app *application) removeListItemHandler(w http.ResponseWriter, r *http.Request) {
+
+	list := app.readList(w, r, true)
+	if list == nil {
+		return
+	}
+
+	movieID := app.readListItemParam(r)
+	if movieID == 0 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err := app.models.Lists.RemoveItem(list.ID, movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeListResponse(w, r, http.StatusOK, list.ID)
+}
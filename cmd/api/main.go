@@ -1,133 +1,177 @@
-package main
-
-import (
-	"context"
-	"database/sql"
-	"flag"
-	"fmt"
-	"log"
-	"net/http"
-	"os"
-	"time"
-
-	// Import the pq driver so that it can register itself with the database/sql
-	// package. Note that we alias this import to the blank identifier, to stop the Go
-	// compiler complaining that the package isn't being used.
-	_ "github.com/lib/pq" /// postgres driver
-
-	"github.com/goddhi/zeliz-movie/internal/data"
-
-)
-
-///  application version number
-const version = "1.0.0"
-
-// struct for configuration settings for the application
-type config struct {
-	port int  // for the server
-	env string // specifies the environment(dev, staging, production)
-	db  struct {
-		dsn	string
-		maxOpenConns int
-		maxIdleConns int
-		maxIdleTime string
-	}
-}
-
-// an application struct to hold dependecncies for the http handlers, helpers, and middleware
-type application struct {
-	config config  // copy of the config strucy
-	logger *log.Logger // 
-	models	data.Models
-
-
-}
-
-func main() {
-	// Declare an instance of the config struct
-	var cfg config
-
-	// Read the value of the port and env command-line flags into the config struct.
-	// the default value is set to 4000 and development
-	flag.IntVar(&cfg.port, "port", 4000, "API server port")
-	flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
-
-	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("GREENLIGHT_DB_DSN"), "PostgreSQL DSN")
-
-	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "PostgresSQL max open connection")
-	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgresSQL max idle connection")
-	flag.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "PostgresSQL max connection idle time")
-
-	flag.Parse() /// allow us pass command line flags when running the application e.g go run ./cmd/api -port=33033 -env=production
-	// Initialize a new logger which writes messages to the standard out stream,
-	// prefixed with the current date and time.
-	logger := log.New(os.Stdout, "", log.Ldate | log.Ltime)
-
-	db, err := openDB(cfg) // creates the connection pool
-	if err != nil {
-		logger.Fatal(err)
-	}
-
-	defer db.Close()
-
-	logger.Printf("database connection pool established")
-	
-	
-	// Declare an instance of the application struct, containing the config struct and
-	// the logger
-	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db), // Use the data.NewModels() function to initialize a Models struct, passing in the	// connection pool as a parameter.
-	}
-
-	// setting up the router and handler
-	// mux := http.NewServeMux()
-	// mux.HandleFunc("/v1/healthcheck", app.healthcheckHandler)
-	
-
-	srv := &http.Server{
-		Addr: fmt.Sprintf(":%d", cfg.port),
-		Handler: app.routes(),
-		IdleTimeout: time.Minute,
-		ReadTimeout: 10 * time.Second,
-		WriteTimeout: 30 * time.Second,
-	}
-
-	//starting the http server
-	logger.Printf("starting %s server on %s", cfg.env, srv.Addr)
-	err = srv.ListenAndServe()
-	log.Fatal(err)
-}
-
-
-func openDB(cfg config) (*sql.DB, error) {
-	db, err := sql.Open("postgres", cfg.db.dsn)
-	if err != nil {
-		return nil, err
-	}
-
-	db.SetMaxOpenConns(cfg.db.maxOpenConns)
-	db.SetMaxIdleConns(cfg.db.maxIdleConns)
-
-	// the time.ParseDuration() function to convert the idle timeout duration string
-// to a time.Duration type.
-	duration, err := time.ParseDuration(cfg.db.maxIdleTime)
-	if err != nil {
-		return nil, err
-	}
-	db.SetConnMaxIdleTime(duration)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	err = db.PingContext(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	return db, err
-}
-
-
-
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	// Import the pq driver so that it can register itself with the database/sql
+	// package. Note that we alias this import to the blank identifier, to stop the Go
+	// compiler complaining that the package isn't being used.
+	_ "github.com/lib/pq" /// postgres driver
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+
+)
+
+///  application version number
+const version = "1.0.0"
+
+// struct for configuration settings for the application
+type config struct {
+	port int  // for the server
+	env string // specifies the environment(dev, staging, production)
+	db  struct {
+		dsn	string
+		maxOpenConns int
+		maxIdleConns int
+		maxIdleTime string
+	}
+	idempotency struct {
+		window time.Duration // how long a stored Idempotency-Key response can be replayed
+	}
+	suggest struct {
+		maxAge time.Duration // how long cached titles are used before being reloaded
+		rps    float64       // requests a second allowed per client IP
+		burst  int
+	}
+	stats struct {
+		ttl time.Duration // how long catalogue statistics are cached
+	}
+	similar struct {
+		maxAge time.Duration // how long the similar movies index is used before being rebuilt
+	}
+}
+
+// an application struct to hold dependecncies for the http handlers, helpers, and middleware
+type application struct {
+	config config  // copy of the config strucy
+	logger *log.Logger // 
+	models	data.Models
+	suggestions *titleSuggester // cached titles for GET /v1/movies/suggest
+	stats *statsCache // recent results for GET /v1/stats/movies
+	similar *similarIndex // in-memory index for GET /v1/movies/:id/similar
+
+
+}
+
+func main() {
+	// Declare an instance of the config struct
+	var cfg config
+
+	// Read the value of the port and env command-line flags into the config struct.
+	// the default value is set to 4000 and development
+	flag.IntVar(&cfg.port, "port", 4000, "API server port")
+	flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
+
+	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("GREENLIGHT_DB_DSN"), "PostgreSQL DSN")
+
+	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "PostgresSQL max open connection")
+	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgresSQL max idle connection")
+	flag.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "PostgresSQL max connection idle time")
+
+	flag.DurationVar(&cfg.idempotency.window, "idempotency-window", 24*time.Hour, "How long Idempotency-Key responses are kept for replay")
+
+	flag.DurationVar(&cfg.suggest.maxAge, "suggest-max-age", 5*time.Minute, "How long cached titles are used for suggestions before being reloaded")
+	flag.Float64Var(&cfg.suggest.rps, "suggest-limiter-rps", 10, "Suggestion requests allowed per second per client")
+	flag.IntVar(&cfg.suggest.burst, "suggest-limiter-burst", 20, "Suggestion requests allowed in a burst per client")
+
+	flag.DurationVar(&cfg.stats.ttl, "stats-cache-ttl", time.Minute, "How long catalogue statistics are cached")
+
+	flag.DurationVar(&cfg.similar.maxAge, "similar-max-age", 15*time.Minute, "How long the similar movies index is used before being rebuilt")
+
+	flag.Parse() /// allow us pass command line flags when running the application e.g go run ./cmd/api -port=33033 -env=production
+	// Initialize a new logger which writes messages to the standard out stream,
+	// prefixed with the current date and time.
+	logger := log.New(os.Stdout, "", log.Ldate | log.Ltime)
+
+	db, err := openDB(cfg) // creates the connection pool
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	defer db.Close()
+
+	logger.Printf("database connection pool established")
+
+	
+	// Declare an instance of the application struct, containing the config struct and
+	// the logger
+	app := &application{
+		config: cfg,
+		logger: logger,
+		models: data.NewModels(db), // Use the data.NewModels() function to initialize a Models struct, passing in the	// connection pool as a parameter.
+	}
+
+	app.suggestions = newTitleSuggester(app.models.Movies.Titles, cfg.suggest.maxAge, logger)
+
+	// Start loading the titles now, so suggestions are ready for the first request.
+	app.suggestions.snapshot()
+
+	app.stats = newStatsCache(cfg.stats.ttl)
+
+	app.similar = newSimilarIndex(app.models.Movies.Features, cfg.similar.maxAge, logger)
+
+	// Build the similar movies index in the background, so the first request for
+	// recommendations doesn't have to wait for it.
+	go func() {
+		if err := app.similar.ensureLoaded(); err != nil {
+			logger.Printf("building similar movies index: %v", err)
+		}
+	}()
+
+	// setting up the router and handler
+	// mux := http.NewServeMux()
+	// mux.HandleFunc("/v1/healthcheck", app.healthcheckHandler)
+	
+
+	srv := &http.Server{
+		Addr: fmt.Sprintf(":%d", cfg.port),
+		Handler: app.routes(),
+		IdleTimeout: time.Minute,
+		ReadTimeout: 10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+
+	//starting the http server
+	logger.Printf("starting %s server on %s", cfg.env, srv.Addr)
+	err = srv.ListenAndServe()
+	log.Fatal(err)
+}
+
+
+func openDB(cfg config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.db.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(cfg.db.maxOpenConns)
+	db.SetMaxIdleConns(cfg.db.maxIdleConns)
+
+	// the time.ParseDuration() function to convert the idle timeout duration string
+// to a time.Duration type.
+	duration, err := time.ParseDuration(cfg.db.maxIdleTime)
+	if err != nil {
+		return nil, err
+	}
+	db.SetConnMaxIdleTime(duration)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return db, err
+}
+
+
+
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/goddhi/zeliz-movie/internal/i18n"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+	"golang.org/x/time/rate"
+)
+
+// negotiateLanguage picks the response language from the Accept-Language header and
+// stores it in the request context for the error helpers to use.
+func (app *application) negotiateLanguage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale := i18n.Negotiate(r.Header.Get("Accept-Language"))
+
+		w.Header().Add("Vary", "Accept-Language")
+		w.Header().Set("Content-Language", locale)
+
+		next.ServeHTTP(w, app.contextSetLocale(r, locale))
+	})
+}
+
+// responseRecorder passes a response through to the client while keeping a copy of the
+// status code and body, so the idempotent middleware can store it.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(statusCode int) {
+	rec.statusCode = statusCode
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// idempotent makes a handler safe to retry. When a request carries an Idempotency-Key
+// header the key is stored with a fingerprint of the request, and a successful response
+// is recorded against it. A retry with the same key and body gets the recorded response
+// replayed instead of running the handler again; reusing the key with a different body
+// or query string is rejected with a 422. Each authenticated user has keys of their own.
+func (app *application) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+
+		v := validator.New()
+		v.CheckCode(len(key) <= 255, "Idempotency-Key", validator.CodeMaxBytes, "must not be more than 255 bytes long", validator.Params{"max": 255})
+		if !v.Valid() {
+			app.failedValidationResponse(w, r, v)
+			return
+		}
+
+		// Read the body up front so it can be fingerprinted, then put it back for the
+		// handler. The limit matches the one readJSON() applies.
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1_048_576))
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Compact the body if it's JSON so that whitespace differences between the
+		// original request and a retry don't count as a different request.
+		compacted := new(bytes.Buffer)
+		if err := json.Compact(compacted, body); err == nil {
+			body = compacted.Bytes()
+		}
+
+		hash := sha256.New()
+		hash.Write([]byte(r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery + "\n"))
+		hash.Write(body)
+		fingerprint := hash.Sum(nil)
+
+		// Keys are only unique to the client that chose them, so an authenticated
+		// user's keys are stored apart from everyone else's. Anonymous requests share
+		// one space, where the fingerprint check stops a clash replaying the wrong
+		// response.
+		if user := app.contextGetUser(r); !user.IsAnonymous() {
+			key = fmt.Sprintf("user:%d/%s", user.ID, key)
+		} else {
+			key = "anonymous/" + key
+		}
+
+		record, reserved, err := app.models.Idempotency.Reserve(key, fingerprint, app.config.idempotency.window)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !reserved {
+			switch {
+			case !bytes.Equal(record.Fingerprint, fingerprint):
+				app.idempotencyKeyReusedResponse(w, r)
+			case !record.Completed:
+				app.idempotencyKeyInProgressResponse(w, r)
+			default:
+				for key, values := range record.Header {
+					w.Header()[key] = values
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(record.StatusCode)
+				w.Write(record.Body)
+			}
+			return
+		}
+
+		// Only successful responses are kept; anything else frees the key so the
+		// client can try again. The key is freed in a deferred call so that happens
+		// even if the handler panics, rather than leaving retries told the request is
+		// in progress until the window runs out.
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := app.models.Idempotency.Release(key); err != nil {
+				app.logError(r, err)
+			}
+		}()
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next(rec, r)
+
+		if rec.statusCode >= 200 && rec.statusCode < 300 {
+			err = app.models.Idempotency.Complete(key, rec.statusCode, w.Header(), rec.body.Bytes())
+			if err != nil {
+				app.logError(r, err)
+				return
+			}
+			completed = true
+		}
+	}
+}
+
+// authenticate looks up the user for the bearer token in the Authorization header and
+// adds them to the request context. Requests without the header carry on as the
+// AnonymousUser; a malformed, unknown or expired token is rejected with a 401.
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The response depends on the Authorization header, so tell caches.
+		w.Header().Add("Vary", "Authorization")
+
+		authorizationHeader := r.Header.Get("Authorization")
+		if authorizationHeader == "" {
+			next.ServeHTTP(w, app.contextSetUser(r, data.AnonymousUser))
+			return
+		}
+
+		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		token := headerParts[1]
+
+		v := validator.New()
+
+		if data.ValidateTokenPlaintext(v, token); !v.Valid() {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		user, err := app.models.Users.GetForToken(data.ScopeAuthentication, token)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.invalidAuthenticationTokenResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, app.contextSetUser(r, user))
+	})
+}
+
+// requireAuthenticatedUser only lets requests from an authenticated user through.
+func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if app.contextGetUser(r).IsAnonymous() {
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// userHasPermission reports whether the request's user has been granted the permission.
+func (app *application) userHasPermission(r *http.Request, code string) (bool, error) {
+	user := app.contextGetUser(r)
+	if user.IsAnonymous() {
+		return false, nil
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return permissions.Include(code), nil
+}
+
+// requirePermission only lets requests through from an authenticated user who has been
+// granted the permission.
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ok, err := app.userHasPermission(r, code)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !ok {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		next(w, r)
+	}
+
+	return app.requireAuthenticatedUser(fn)
+}
+
+// rateLimit allows each client IP address an average of rps requests a second to next,
+// with bursts of up to burst requests, and answers the rest with a 429. Each call keeps
+// its own set of limiters, so routes wrapped separately are limited separately.
+func (app *application) rateLimit(rps float64, burst int, next http.HandlerFunc) http.HandlerFunc {
+	type client struct {
+		limiter  *rate.Limiter
+		lastSeen time.Time
+	}
+
+	var (
+		mu      sync.Mutex
+		clients = make(map[string]*client)
+	)
+
+	// Forget clients that haven't been seen for a while, so the map doesn't grow
+	// without bound.
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+
+			mu.Lock()
+			for ip, client := range clients {
+				if time.Since(client.lastSeen) > 3*time.Minute {
+					delete(clients, ip)
+				}
+			}
+			mu.Unlock()
+		}
+	}()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		mu.Lock()
+
+		if _, found := clients[ip]; !found {
+			clients[ip] = &client{limiter: rate.NewLimiter(rate.Limit(rps), burst)}
+		}
+
+		clients[ip].lastSeen = time.Now()
+
+		if !clients[ip].limiter.Allow() {
+			mu.Unlock()
+			app.rateLimitExceededResponse(w, r)
+			return
+		}
+
+		mu.Unlock()
+
+		next(w, r)
+	}
+}
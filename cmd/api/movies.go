@@ -1,258 +1,501 @@
-package main
-
-import (
-	"fmt"
-	"net/http"
-	"errors"
-
-	"github.com/goddhi/zeliz-movie/internal/data"
-	"github.com/goddhi/zeliz-movie/internal/validator"
-)
-
-
-func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
-	
-	var input struct {
-		Title 	string			`json:"title"`
-		Year	int32			`json:"year"`
-		Runtime data.Runtime	`json:"runtime"`
-		Genres  []string		`json:"genres"`
-	}
-
-	err := app.readJSON(w, r, &input)
-	if err != nil {
-		app.badRequestResponse(w, r, err)
-		return
-	}
-
-	movie := &data.Movie{
-		Title: 		input.Title,
-		Year: 		input.Year,
-		Runtime: 	input.Runtime,
-		Genres: 	input.Genres,
-	}
-
-	// initialized a validator instance from the validator 
-	v := validator.New()
-	
-	// Call the ValidateMovie() function and return a response containing the errors if
-	// any of the checks fail.
-
-	if data.ValidateMovie(v, movie); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
-		return
-	}
-
-	err = app.models.Movies.Insert(movie)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-	}
-
-	// When sending a HTTP response, we want to include a Location header to let the
-	// client know which URL they can find the newly-created resource at. We make an
-	// empty http.Header map and then use the Set() method to add a new Location header,
-	// interpolating the system-generated ID for our new movie in the URL.
-
-	headers := make(http.Header)
-	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
-	
-	// Write a JSON response with a 201 Created status code, the movie data in the
-	// response body, and the Location header.
-	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-	}
-
-}
-
-
-func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request) {
-
-		id, err := app.readIDParam(r)
-		if err != nil {
-			app.notFoundResponse(w, r)
-			return
-		}
-
-		movie, err := app.models.Movies.Get(id)
-		if err != nil {
-			switch  {
-			case errors.Is(err, data.ErrRecordNotFound):
-				app.notFoundResponse(w, r)	
-			default:
-				app.serverErrorResponse(w, r, err)		
-			}
-			return
-		}
-
-		err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
-		}
-}
-
-
-func (app *application) updtaeMovieHandler(w http.ResponseWriter, r *http.Request) {
-
-	id, err := app.readIDParam(r)
-	if err != nil {
-		app.notFoundResponse(w, r)
-		return
-	}
-
-	movie, err := app.models.Movies.Get(id)
-	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
-		default:
-			app.serverErrorResponse(w, r, err)
-		}
-		return
-	}
-
-	// Declare an input struct to hold the expected data from the client.
-var input struct {
-	Title *string `json:"title"`
-	Year *int32 `json:"year"`
-	Runtime *data.Runtime `json:"runtime"`
-	Genres *[]string `json:"genres"`
-	}
-	
-	err = app.readJSON(w, r, &input)
-	if err != nil {
-		app.badRequestResponse(w, r, err)
-		return 
-	}
-
-	// path update implementation
-	// If the input.Title value is nil then we know that no corresponding "title" key/
-	// value pair was provided in the JSON request body. So we move on and leave the
-	// movie record unchanged. Otherwise, we update the movie record with the new title
-	// value. Importantly, because input.Title is a now a pointer to a string, we need
-	// to dereference the pointer using the * operator to get the underlying value
-	// before assigning it to our movie record.
-
-
-	if input.Title != nil {
-		movie.Title = *input.Title
-	}
-
-	if input.Year != nil {
-		movie.Year = *input.Year
-	}
-
-
-	if input.Runtime != nil {
-		movie.Runtime = *input.Runtime
-	}
-
-	if input.Genres != nil {
-		movie.Genres = *input.Genres
-	}
-
-	// Copy the values from the request body to the appropriate fields of the movie
-	// record
-
-	// movie.Title = input.Title
-	// movie.Year 	= input.Year
-	// movie.Runtime = input.Runtime
-	// movie.Genres  = input.Genres
-
-	v := validator.New()
-
-	if data.ValidateMovie(v, movie); !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
-		return 
-	}
-
-	// Pass the updated movie record to our new Update() method.
-	err = app.models.Movies.Update(movie)
-	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrEditConflict):
-			app.EditConflictResponse(w, r)
-		default:
-			app.serverErrorResponse(w, r, err)
-		}
-	return
-	}
-
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-	}
-}
-
-func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Request) {
-	id, err := app.readIDParam(r)
-	if err != nil {
-		app.notFoundResponse(w, r,)
-		return 
-	}
-
-	err = app.models.Movies.Delete(id)
-	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
-		default:
-			app.serverErrorResponse(w, r, err)
-		}
-		return
-	}
-	
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil)
-	if err!= nil {
-		app.serverErrorResponse(w, r, err)
-	}
-}
-
-func (app *application) listMovieHandler(w http.ResponseWriter, r *http.Request) {
-
-	var input struct {
-		Title 			string
-		Genres			[]string
-		data.Filters
-
-	}
-
-	v := validator.New()
-
-	// Call r.URL.Query() to get the url.Values map containing the query string data.
-	qs := r.URL.Query()
-
-	input.Title = app.readString(qs, "title", "")
-	input.Genres = app.readCSV(qs, "genres", []string{})
-
-
-	input.Filters.Page = app.readInt(qs, "page", 1, v)
-	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
-
-	// Extract the sort query string value, falling back to "id" if it is not provided
-	// by the client (which will imply a ascending sort on movie ID).
-	input.Filters.Sort = app.readString(qs, "sort", "id")
-	/// sorting based on ascending and descending(-) order
-	input.Filters.SortStatelist = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
-
-	// evaluate the validation checks on the filters structs and send a response if it contains an error, if no error it sends the field
-	
-	if data.ValidateFilters(v, input.Filters); !v.Valid()  {
-		app.failedValidationResponse(w, r, v.Errors)
-		return
-	}
-
-	movies, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
-
-	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies}, nil)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-	}
-
-	
-}
-
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"errors"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+
+func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
+	
+	var input struct {
+		Title 	string			`json:"title"`
+		Year	int32			`json:"year"`
+		Runtime data.Runtime	`json:"runtime"`
+		Genres  []string		`json:"genres"`
+		ExternalIDs map[string]string	`json:"external_ids"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	movie := &data.Movie{
+		Title: 		input.Title,
+		Year: 		input.Year,
+		Runtime: 	input.Runtime,
+		Genres: 	input.Genres,
+		ExternalIDs: input.ExternalIDs,
+	}
+
+	// initialized a validator instance from the validator 
+	v := validator.New()
+
+	runtimeFormat := app.readRuntimeFormat(r, v)
+	
+	// Call the ValidateMovie() function and return a response containing the errors if
+	// any of the checks fail.
+
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// Store genres by their slug in the vocabulary, so "Sci-Fi" and "science fiction"
+	// both end up as "science-fiction".
+	movie.Genres, err = app.normaliseGenres(v, movie.Genres)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Movies.Insert(movie)
+	if err != nil {
+		switch {
+		case app.checkExternalIDConflict(v, err):
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.suggestions.invalidate()
+	app.similar.upsert(movie)
+
+	// When sending a HTTP response, we want to include a Location header to let the
+	// client know which URL they can find the newly-created resource at. We make an
+	// empty http.Header map and then use the Set() method to add a new Location header,
+	// interpolating the system-generated ID for our new movie in the URL.
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+
+	movie.SetRuntimeFormat(runtimeFormat)
+	
+	// Write a JSON response with a 201 Created status code, the movie data in the
+	// response body, and the Location header.
+	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+
+func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request) {
+
+		id, err := app.readIDParam(r)
+		if err != nil {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		v := validator.New()
+
+		runtimeFormat := app.readRuntimeFormat(r, v)
+
+		fields := app.readMovieFields(r.URL.Query(), v)
+
+		// Related data that is only loaded when asked for, e.g. ?include=credits
+		include := app.readIncludes(r.URL.Query(), v, movieIncludeNames())
+
+		if !v.Valid() {
+			app.failedValidationResponse(w, r, v)
+			return
+		}
+
+		movies := app.models.Movies.Select(fields)
+
+		movie, err := movies.Get(id)
+		if err != nil {
+			switch  {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)	
+			default:
+				app.serverErrorResponse(w, r, err)		
+			}
+			return
+		}
+
+		err = app.loadIncludes([]*data.Movie{movie}, include)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		movie.SetRuntimeFormat(runtimeFormat)
+
+		err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+}
+
+
+// showMovieByExternalIDHandler looks a movie up by one of its external identifiers, e.g.
+// GET /v1/movies/by-external/imdb/tt0111161.
+func (app *application) showMovieByExternalIDHandler(w http.ResponseWriter, r *http.Request) {
+
+	params := httprouter.ParamsFromContext(r.Context())
+
+	source := params.ByName("source")
+	value := params.ByName("value")
+
+	v := validator.New()
+
+	v.CheckCode(data.ExternalSources[source] != nil, "source", validator.CodeNotPermitted, "unsupported source", validator.Params{"allowed": data.ExternalSourceNames()})
+
+	runtimeFormat := app.readRuntimeFormat(r, v)
+
+	fields := app.readMovieFields(r.URL.Query(), v)
+
+	include := app.readIncludes(r.URL.Query(), v, movieIncludeNames())
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	movie, err := app.models.Movies.Select(fields).GetByExternalID(source, value)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.loadIncludes([]*data.Movie{movie}, include)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	movie.SetRuntimeFormat(runtimeFormat)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+
+func (app *application) updtaeMovieHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Declare an input struct to hold the expected data from the client.
+	var input movieInput
+	
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return 
+	}
+
+	// path update implementation: only the fields present in the request body are
+	// copied onto the movie record.
+	input.apply(movie)
+
+	// Copy the values from the request body to the appropriate fields of the movie
+	// record
+
+	// movie.Title = input.Title
+	// movie.Year 	= input.Year
+	// movie.Runtime = input.Runtime
+	// movie.Genres  = input.Genres
+
+	v := validator.New()
+
+	runtimeFormat := app.readRuntimeFormat(r, v)
+
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return 
+	}
+
+	// Only genres sent in this request are normalised, so a movie can still be updated
+	// if it has a genre that has since been removed from the vocabulary.
+	if input.Genres != nil {
+		movie.Genres, err = app.normaliseGenres(v, movie.Genres)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !v.Valid() {
+			app.failedValidationResponse(w, r, v)
+			return
+		}
+	}
+
+	// Pass the updated movie record to our new Update() method.
+	err = app.models.Movies.Update(movie)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.EditConflictResponse(w, r)
+		case app.checkExternalIDConflict(v, err):
+			app.failedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+	return
+	}
+
+	app.suggestions.invalidate()
+	app.similar.upsert(movie)
+
+	movie.SetRuntimeFormat(runtimeFormat)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r,)
+		return 
+	}
+
+	err = app.models.Movies.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.suggestions.invalidate()
+	app.similar.remove(id)
+	
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully moved to trash"}, nil)
+	if err!= nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listMovieHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		data.MovieFilter
+		data.Filters
+		Facets []string
+
+	}
+
+	v := validator.New()
+
+	// Call r.URL.Query() to get the url.Values map containing the query string data.
+	qs := r.URL.Query()
+
+	input.MovieFilter = app.readMovieFilter(qs, v)
+	input.Facets = app.readCSV(qs, "facets", []string{})
+
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	// Extract the sort query string value, falling back to "id" if it is not provided
+	// by the client (which will imply a ascending sort on movie ID). A title search
+	// is sorted by relevance instead, best match first.
+	defaultSort := "id"
+	if input.Title != "" {
+		defaultSort = "relevance"
+	}
+	input.Filters.Sort = app.readString(qs, "sort", defaultSort)
+	/// sorting based on ascending and descending(-) order
+	input.Filters.SortStatelist = []string{"id", "title", "year", "runtime", "average_rating", "rating_count", "relevance", "-id", "-title", "-year", "-runtime", "-average_rating", "-rating_count"}
+
+	v.CheckCode(input.Filters.Sort != "relevance" || input.Title != "", "sort", validator.CodeInvalid, "relevance can only be used when searching by title", nil)
+
+	for _, facet := range input.Facets {
+		v.CheckCode(validator.In(facet, data.FacetNames...), "facets", validator.CodeNotPermitted, "invalid facet", validator.Params{"allowed": data.FacetNames})
+	}
+	v.CheckCode(validator.Unique(input.Facets), "facets", validator.CodeDuplicate, "must not contain duplicate values", nil)
+
+	runtimeFormat := app.readRuntimeFormat(r, v)
+
+	fields := app.readMovieFields(qs, v)
+
+	include := app.readIncludes(qs, v, movieIncludeNames())
+
+	// evaluate the validation checks on the filters structs and send a response if it contains an error, if no error it sends the field
+	
+	if data.ValidateFilters(v, input.Filters); !v.Valid()  {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err := app.resolveGenreFilter(&input.MovieFilter)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var (
+		movies []*data.Movie
+		facets map[string][]*data.FacetBucket
+	)
+
+	model := app.models.Movies.Select(fields)
+
+	if len(input.Facets) > 0 {
+		movies, facets, err = model.GetAllWithFacets(input.MovieFilter, input.Filters, input.Facets)
+	} else {
+		movies, err = model.GetAll(input.MovieFilter, input.Filters)
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Related data is loaded for the whole page at once.
+	err = app.loadIncludes(movies, include)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for _, movie := range movies {
+		movie.SetRuntimeFormat(runtimeFormat)
+	}
+
+	env := envelope{"movies": movies}
+	metadata := envelope{}
+
+	if facets != nil {
+		metadata["facets"] = facets
+	}
+
+	// If an exact title search on the first page found nothing, offer the closest title
+	// we have, in case it was misspelt.
+	if len(movies) == 0 && input.Title != "" && input.Match == data.MatchExact && input.Filters.Page == 1 {
+		suggestion, err := app.models.Movies.SuggestTitle(input.Title)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if suggestion != "" {
+			metadata["did_you_mean"] = suggestion
+		}
+	}
+
+	if len(metadata) > 0 {
+		env["metadata"] = metadata
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+	
+}
+
+
+// movieInput holds the movie fields a client can send when updating a movie.
+// The fields are pointers so that a missing key (nil) can be told apart from a zero
+// value.
+type movieInput struct {
+	Title   *string       `json:"title"`
+	Year    *int32        `json:"year"`
+	Runtime *data.Runtime `json:"runtime"`
+	Genres  *[]string     `json:"genres"`
+
+	// ExternalIDs only needs the sources being changed; an empty value removes one.
+	ExternalIDs map[string]string `json:"external_ids"`
+}
+
+// apply copies the fields that were provided onto movie.
+// If input.Title is nil then no corresponding "title" key/value pair was provided in
+// the JSON request body, so we leave the movie record unchanged. Otherwise we
+// dereference the pointer to get the underlying value and assign it to the record.
+func (input movieInput) apply(movie *data.Movie) {
+	if input.Title != nil {
+		movie.Title = *input.Title
+	}
+
+	if input.Year != nil {
+		movie.Year = *input.Year
+	}
+
+	if input.Runtime != nil {
+		movie.Runtime = *input.Runtime
+	}
+
+	if input.Genres != nil {
+		movie.Genres = *input.Genres
+	}
+
+	if input.ExternalIDs != nil {
+		ids := make(map[string]string, len(movie.ExternalIDs)+len(input.ExternalIDs))
+		for source, value := range movie.ExternalIDs {
+			ids[source] = value
+		}
+
+		for source, value := range input.ExternalIDs {
+			if value == "" {
+				delete(ids, source)
+				continue
+			}
+			ids[source] = value
+		}
+
+		movie.ExternalIDs = ids
+	}
+}
+
+// checkExternalIDConflict reports whether err is because one of a movie's external ids
+// belongs to another movie, recording a validation error for it in v if so.
+func (app *application) checkExternalIDConflict(v *validator.Validator, err error) bool {
+	var conflict *data.ExternalIDConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+
+	if conflict.Trashed {
+		v.AddCodedError("external_ids."+conflict.Source, validator.CodeTakenInTrash, fmt.Sprintf("is already assigned to movie %d, which is in the trash", conflict.MovieID), validator.Params{"value": conflict.Value, "movie_id": conflict.MovieID})
+		return true
+	}
+
+	v.AddCodedError("external_ids."+conflict.Source, validator.CodeTaken, "is already assigned to another movie", validator.Params{"value": conflict.Value})
+	return true
+}
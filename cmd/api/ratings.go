@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+// readRating reads and validates the score for the authenticated user's rating of the
+// movie in the URL. If it returns nil a response has already been sent.
+func (app *application) readRating(w http.ResponseWriter, r *http.Request) *data.Rating {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil
+	}
+
+	var input struct {
+		Score int16 `json:"score"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil
+	}
+
+	rating := &data.Rating{
+		MovieID: id,
+		UserID:  app.contextGetUser(r).ID,
+		Score:   input.Score,
+	}
+
+	v := validator.New()
+
+	if data.ValidateRating(v, rating); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return nil
+	}
+
+	return rating
+}
+
+// writeRatingResponse sends the rating along with the movie, so the client gets the
+// movie's new average_rating and rating_count.
+func (app *application) writeRatingResponse(w http.ResponseWriter, r *http.Request, status int, rating *data.Rating) {
+	movie, err := app.models.Movies.Get(rating.MovieID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, status, envelope{"rating": rating, "movie": movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createRatingHandler rates a movie the user hasn't rated before.
+func (app *application) createRatingHandler(w http.ResponseWriter, r *http.Request) {
+
+	rating := app.readRating(w, r)
+	if rating == nil {
+		return
+	}
+
+	err := app.models.Ratings.Insert(rating)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateRating):
+			app.ratingExistsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.similar.setRated(rating.MovieID, rating.UserID, true)
+
+	app.writeRatingResponse(w, r, http.StatusCreated, rating)
+}
+
+// updateRatingHandler sets the user's score for a movie, whether or not they've rated it
+// before.
+func (app *application) updateRatingHandler(w http.ResponseWriter, r *http.Request) {
+
+	rating := app.readRating(w, r)
+	if rating == nil {
+		return
+	}
+
+	created, err := app.models.Ratings.Upsert(rating)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+		app.similar.setRated(rating.MovieID, rating.UserID, true)
+	}
+
+	app.writeRatingResponse(w, r, status, rating)
+}
+
+func (app *application) deleteRatingHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Ratings.Delete(app.contextGetUser(r).ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.similar.setRated(id, app.contextGetUser(r).ID, false)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "rating successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+// reviewSortStatelist is shared by both review listings.
+var reviewSortStatelist = []string{"created_at", "updated_at", "id", "-created_at", "-updated_at", "-id"}
+
+// createReviewHandler adds the user's review of a movie. It starts out pending and isn't
+// shown publicly until a moderator approves it.
+func (app *application) createReviewHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Body string `json:"body"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	review := &data.Review{
+		MovieID: movie.ID,
+		UserID:  app.contextGetUser(r).ID,
+		Body:    input.Body,
+	}
+
+	v := validator.New()
+
+	if data.ValidateReview(v, review); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Reviews.Insert(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateReview):
+			app.reviewExistsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/reviews/%d", review.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"review": review}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listMovieReviewsHandler returns a page of a movie's approved reviews.
+func (app *application) listMovieReviewsHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var filters data.Filters
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = app.readString(qs, "sort", "-created_at")
+	filters.SortStatelist = reviewSortStatelist
+
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	_, err = app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	reviews, err := app.models.Reviews.GetAll(id, data.ReviewApproved, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"reviews": reviews}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listReviewsHandler is the moderation queue: a page of reviews in a given status
+// (pending by default), optionally for a single movie.
+func (app *application) listReviewsHandler(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Status  string
+		MovieID int
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Status = app.readString(qs, "status", data.ReviewPending)
+	input.MovieID = app.readInt(qs, "movie_id", 0, v)
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "created_at")
+	input.Filters.SortStatelist = reviewSortStatelist
+
+	v.CheckCode(validator.In(input.Status, data.ReviewStatuses...), "status", validator.CodeNotPermitted, "invalid status", validator.Params{"allowed": data.ReviewStatuses})
+	v.CheckCode(input.MovieID >= 0, "movie_id", validator.CodeMinValue, "must not be negative", validator.Params{"min": 0})
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	reviews, err := app.models.Reviews.GetAll(int64(input.MovieID), input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"reviews": reviews}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readReview fetches the review in the URL, provided the request's user can see it:
+// anyone can see an approved review, but pending and rejected ones are only visible to
+// their author and to moderators. If it returns nil a response has already been sent.
+func (app *application) readReview(w http.ResponseWriter, r *http.Request) *data.Review {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil
+	}
+
+	review, err := app.models.Reviews.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+
+	if review.Status == data.ReviewApproved || review.UserID == app.contextGetUser(r).ID {
+		return review
+	}
+
+	moderator, err := app.userHasPermission(r, data.PermissionModerateReviews)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return nil
+	}
+
+	if !moderator {
+		app.notFoundResponse(w, r)
+		return nil
+	}
+
+	return review
+}
+
+func (app *application) showReviewHandler(w http.ResponseWriter, r *http.Request) {
+
+	review := app.readReview(w, r)
+	if review == nil {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"review": review}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateReviewHandler lets the author edit their review, which sends it back for
+// moderation.
+func (app *application) updateReviewHandler(w http.ResponseWriter, r *http.Request) {
+
+	review := app.readReview(w, r)
+	if review == nil {
+		return
+	}
+
+	if review.UserID != app.contextGetUser(r).ID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Body *string `json:"body"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Body != nil {
+		review.Body = *input.Body
+	}
+
+	v := validator.New()
+
+	if data.ValidateReview(v, review); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Reviews.Update(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.EditConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"review": review}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteReviewHandler removes a review. Authors can delete their own reviews, and
+// moderators can delete anyone's.
+func (app *application) deleteReviewHandler(w http.ResponseWriter, r *http.Request) {
+
+	review := app.readReview(w, r)
+	if review == nil {
+		return
+	}
+
+	if review.UserID != app.contextGetUser(r).ID {
+		moderator, err := app.userHasPermission(r, data.PermissionModerateReviews)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !moderator {
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+
+	err := app.models.Reviews.Delete(review.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "review successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// moderateReviewHandler approves or rejects a review. Sending the version the moderator
+// read guards against deciding on a review that has been edited in the meantime.
+func (app *application) moderateReviewHandler(w http.ResponseWriter, r *http.Request) {
+
+	review := app.readReview(w, r)
+	if review == nil {
+		return
+	}
+
+	var input struct {
+		Status  string `json:"status"`
+		Note    string `json:"note"`
+		Version *int32 `json:"version"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Version != nil && *input.Version != review.Version {
+		app.EditConflictResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateModeration(v, review, input.Status, input.Note); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Reviews.Moderate(review, input.Status, input.Note, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.EditConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"review": review}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
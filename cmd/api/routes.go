@@ -1,26 +1,87 @@
-package main
-
-import (
-	"net/http"
-
-	"github.com/julienschmidt/httprouter"
-)
-
-func (app *application) routes() *httprouter.Router {
-	router := httprouter.New()  // initialized a new router instance
-
-	router.NotFound = http.HandlerFunc(app.notFoundResponse) // using custom error other than the default http router notFound error
-	
-	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedRespose)   // using custom error other than the default http router methodNotAllowed error
-
-	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/movies", app.listMovieHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/movies", app.createMovieHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.showMovieHandler)
-	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.updtaeMovieHandler)
-	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.deleteMovieHandler)
-	
-	return router 
-}
-
-
+package main
+
+import (
+	"net/http"
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/julienschmidt/httprouter"
+)
+
+func (app *application) routes() http.Handler {
+	router := httprouter.New()  // initialized a new router instance
+
+	router.NotFound = http.HandlerFunc(app.notFoundResponse) // using custom error other than the default http router notFound error
+	
+	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedRespose)   // using custom error other than the default http router methodNotAllowed error
+
+	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/movies", app.listMovieHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/movies", app.idempotent(app.createMovieHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.showMovieHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.updtaeMovieHandler)
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.deleteMovieHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/restore", app.requirePermission(data.PermissionManageMovies, app.restoreMovieHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/credits", app.requirePermission(data.PermissionManagePeople, app.updateMovieCreditsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/rating", app.requireAuthenticatedUser(app.createRatingHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/rating", app.requireAuthenticatedUser(app.updateRatingHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/rating", app.requireAuthenticatedUser(app.deleteRatingHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/reviews", app.listMovieReviewsHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/reviews", app.requireAuthenticatedUser(app.createReviewHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/similar", app.similarMoviesHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/reviews", app.requirePermission(data.PermissionModerateReviews, app.listReviewsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/reviews/:id", app.showReviewHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/reviews/:id", app.requireAuthenticatedUser(app.updateReviewHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/reviews/:id", app.requireAuthenticatedUser(app.deleteReviewHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/reviews/:id/moderation", app.requirePermission(data.PermissionModerateReviews, app.moderateReviewHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/stats/movies", app.movieStatsHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/people", app.listPeopleHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/people", app.requirePermission(data.PermissionManagePeople, app.createPersonHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/people/:id", app.showPersonHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/people/:id", app.requirePermission(data.PermissionManagePeople, app.updatePersonHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/people/:id", app.requirePermission(data.PermissionManagePeople, app.deletePersonHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/lists", app.listListsHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/lists", app.requireAuthenticatedUser(app.createListHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/lists/:id", app.showListHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/lists/:id", app.requireAuthenticatedUser(app.updateListHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/lists/:id", app.requireAuthenticatedUser(app.deleteListHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/lists/:id/items", app.requireAuthenticatedUser(app.addListItemHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/lists/:id/items/:movie_id", app.requireAuthenticatedUser(app.moveListItemHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/lists/:id/items/:movie_id", app.requireAuthenticatedUser(app.removeListItemHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/genres", app.listGenresHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/genres", app.requirePermission(data.PermissionManageGenres, app.createGenreHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/genres/:slug", app.showGenreHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/genres/:slug", app.requirePermission(data.PermissionManageGenres, app.updateGenreHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/genres/:slug", app.requirePermission(data.PermissionManageGenres, app.deleteGenreHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/genres/:slug/merge", app.requirePermission(data.PermissionManageGenres, app.mergeGenreHandler))
+
+	// httprouter won't register a fixed path segment in the same place as a wildcard
+	// (GET /v1/movies/export next to GET /v1/movies/:id), so the fixed movie routes get
+	// a router of their own. Anything it doesn't match falls through to the main router.
+	fixed := httprouter.New()
+
+	fixed.NotFound = router
+	// Other methods on the fixed paths fall through too, and get the main router's 404
+	// as they would if the fixed router weren't there.
+	fixed.HandleMethodNotAllowed = false
+
+	fixed.HandlerFunc(http.MethodPost, "/v1/movies/batch", app.requirePermission(data.PermissionManageMovies, app.batchMovieHandler))
+	fixed.HandlerFunc(http.MethodGet, "/v1/movies/export", app.exportMoviesHandler)
+	fixed.HandlerFunc(http.MethodGet, "/v1/movies/trash", app.requirePermission(data.PermissionManageMovies, app.listTrashHandler))
+	fixed.HandlerFunc(http.MethodGet, "/v1/movies/suggest", app.rateLimit(app.config.suggest.rps, app.config.suggest.burst, app.suggestMoviesHandler))
+	fixed.HandlerFunc(http.MethodGet, "/v1/movies/by-external/:source/:value", app.showMovieByExternalIDHandler)
+	
+	// Wrap the router with the language negotiation middleware so every response,
+	// including the router's own 404 and 405s, is rendered in the client's language.
+	// Authentication sits inside it so its 401s are translated too.
+	return app.negotiateLanguage(app.authenticate(fixed))
+}
+
+
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+// How much each signal counts towards a similarity score. Shared ratings only count
+// when both movies have been rated; otherwise the other weights are scaled up to fill
+// in for it.
+const (
+	similarGenreWeight   = 0.5
+	similarYearWeight    = 0.2
+	similarRuntimeWeight = 0.1
+	similarRatingWeight  = 0.2
+)
+
+// Differences at which year and runtime stop counting towards similarity at all.
+const (
+	similarYearRange    = 20.0
+	similarRuntimeRange = 60.0
+)
+
+// similarEntry is a movie as held in the similar movies index.
+type similarEntry struct {
+	id      int64
+	title   string
+	year    int32
+	runtime int32
+	genres  map[string]bool
+	raters  map[int64]bool
+}
+
+// SimilarMovie is a recommendation, with the score it was ranked by.
+type SimilarMovie struct {
+	ID    int64   `json:"id"`
+	Title string  `json:"title"`
+	Year  int32   `json:"year"`
+	Score float64 `json:"score"`
+}
+
+// similarIndex holds every movie's genres, year, runtime and raters in memory, along
+// with inverted indexes from genres and raters to movies, so the candidates for a
+// recommendation are found without scanning the catalogue. It's built from the database
+// on first use, then kept up to date as movies and ratings are written through the API.
+// Changes made elsewhere, such as imports, are picked up by rebuilding it in the
+// background once it's older than maxAge.
+type similarIndex struct {
+	load   func() ([]*data.MovieFeatures, error)
+	maxAge time.Duration
+	logger *log.Logger
+
+	mu       sync.RWMutex
+	movies   map[int64]*similarEntry
+	byGenre  map[string]map[int64]bool
+	byRater  map[int64]map[int64]bool
+	loadedAt time.Time
+
+	building   sync.Mutex  // held while the index is being loaded
+	rebuilding atomic.Bool // set while a load is running
+	refreshing atomic.Bool // set while a background rebuild is scheduled or running
+	stale      atomic.Bool // set when a change may have been lost to a rebuild
+}
+
+func newSimilarIndex(load func() ([]*data.MovieFeatures, error), maxAge time.Duration, logger *log.Logger) *similarIndex {
+	return &similarIndex{load: load, maxAge: maxAge, logger: logger}
+}
+
+// ensureLoaded builds the index if it hasn't been built yet, waiting for it, and starts
+// a background rebuild if it's out of date.
+func (ix *similarIndex) ensureLoaded() error {
+	ix.mu.RLock()
+	loaded, loadedAt := ix.movies != nil, ix.loadedAt
+	ix.mu.RUnlock()
+
+	if !loaded {
+		return ix.rebuild(false)
+	}
+
+	if (ix.stale.Load() || time.Since(loadedAt) > ix.maxAge) && ix.refreshing.CompareAndSwap(false, true) {
+		go func() {
+			defer ix.refreshing.Store(false)
+
+			if err := ix.rebuild(true); err != nil {
+				ix.logger.Printf("rebuilding similar movies index: %v", err)
+			}
+		}()
+	}
+
+	return nil
+}
+
+// rebuild loads the index from the database and swaps it in. Only one load runs at a
+// time; unless force is set, a caller that had to wait for another load doesn't repeat
+// it.
+func (ix *similarIndex) rebuild(force bool) error {
+	ix.building.Lock()
+	defer ix.building.Unlock()
+
+	if !force {
+		ix.mu.RLock()
+		loaded := ix.movies != nil
+		ix.mu.RUnlock()
+
+		if loaded {
+			return nil
+		}
+	}
+
+	// A change applied to the old index while the load is running may not be in the
+	// new one, so it marks the index stale to have it rebuilt again.
+	ix.rebuilding.Store(true)
+	defer ix.rebuilding.Store(false)
+	ix.stale.Store(false)
+
+	features, err := ix.load()
+	if err != nil {
+		return err
+	}
+
+	movies := make(map[int64]*similarEntry, len(features))
+	byGenre := make(map[string]map[int64]bool)
+	byRater := make(map[int64]map[int64]bool)
+
+	for _, f := range features {
+		entry := &similarEntry{id: f.ID, title: f.Title, year: f.Year, runtime: f.Runtime, genres: make(map[string]bool), raters: make(map[int64]bool)}
+
+		for _, genre := range f.Genres {
+			entry.genres[genre] = true
+			addToSet(byGenre, genre, f.ID)
+		}
+
+		for _, rater := range f.Raters {
+			entry.raters[rater] = true
+			addToSet(byRater, rater, f.ID)
+		}
+
+		movies[f.ID] = entry
+	}
+
+	ix.mu.Lock()
+	ix.movies, ix.byGenre, ix.byRater, ix.loadedAt = movies, byGenre, byRater, time.Now()
+	ix.mu.Unlock()
+
+	return nil
+}
+
+// invalidate marks the index out of date, so the next request rebuilds it. It's for
+// writes that change too many movies to apply to the index one by one.
+func (ix *similarIndex) invalidate() {
+	ix.stale.Store(true)
+}
+
+// changed is called by every update to the index.
+func (ix *similarIndex) changed() {
+	if ix.rebuilding.Load() {
+		ix.stale.Store(true)
+	}
+}
+
+func addToSet[K comparable](sets map[K]map[int64]bool, key K, id int64) {
+	if sets[key] == nil {
+		sets[key] = make(map[int64]bool)
+	}
+	sets[key][id] = true
+}
+
+func removeFromSet[K comparable](sets map[K]map[int64]bool, key K, id int64) {
+	delete(sets[key], id)
+	if len(sets[key]) == 0 {
+		delete(sets, key)
+	}
+}
+
+// upsert adds a new movie to the index or updates a changed one. Until the index has
+// been built there's nothing to update, and building it will pick up the change.
+func (ix *similarIndex) upsert(movie *data.Movie) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.changed()
+
+	if ix.movies == nil {
+		return
+	}
+
+	entry, ok := ix.movies[movie.ID]
+	if !ok {
+		entry = &similarEntry{id: movie.ID, raters: make(map[int64]bool)}
+		ix.movies[movie.ID] = entry
+	}
+
+	for genre := range entry.genres {
+		removeFromSet(ix.byGenre, genre, movie.ID)
+	}
+
+	entry.title, entry.year, entry.runtime = movie.Title, movie.Year, int32(movie.Runtime)
+	entry.genres = make(map[string]bool, len(movie.Genres))
+
+	for _, genre := range movie.Genres {
+		entry.genres[genre] = true
+		addToSet(ix.byGenre, genre, movie.ID)
+	}
+}
+
+// restore puts a movie back in the index along with its raters, which remove dropped
+// when it was moved to the trash.
+func (ix *similarIndex) restore(f *data.MovieFeatures) {
+	ix.upsert(&data.Movie{ID: f.ID, Title: f.Title, Year: f.Year, Runtime: data.Runtime(f.Runtime), Genres: f.Genres})
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	entry, ok := ix.movies[f.ID]
+	if !ok {
+		return
+	}
+
+	for _, rater := range f.Raters {
+		entry.raters[rater] = true
+		addToSet(ix.byRater, rater, f.ID)
+	}
+}
+
+// remove takes a deleted movie out of the index.
+func (ix *similarIndex) remove(id int64) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.changed()
+
+	entry, ok := ix.movies[id]
+	if !ok {
+		return
+	}
+
+	for genre := range entry.genres {
+		removeFromSet(ix.byGenre, genre, id)
+	}
+	for rater := range entry.raters {
+		removeFromSet(ix.byRater, rater, id)
+	}
+
+	delete(ix.movies, id)
+}
+
+// setRated records whether a user has a rating for a movie.
+func (ix *similarIndex) setRated(movieID, userID int64, rated bool) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.changed()
+
+	entry, ok := ix.movies[movieID]
+	if !ok {
+		return
+	}
+
+	if rated {
+		entry.raters[userID] = true
+		addToSet(ix.byRater, userID, movieID)
+	} else {
+		delete(entry.raters, userID)
+		removeFromSet(ix.byRater, userID, movieID)
+	}
+}
+
+// similar returns up to limit movies most like the movie with the given id, best first.
+// The candidates are the movies sharing a genre or a rater with it. It returns
+// data.ErrRecordNotFound if the movie isn't in the index.
+func (ix *similarIndex) similar(id int64, limit int) ([]*SimilarMovie, error) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	movie, ok := ix.movies[id]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+
+	candidates := make(map[int64]bool)
+	for genre := range movie.genres {
+		for other := range ix.byGenre[genre] {
+			candidates[other] = true
+		}
+	}
+	for rater := range movie.raters {
+		for other := range ix.byRater[rater] {
+			candidates[other] = true
+		}
+	}
+	delete(candidates, id)
+
+	results := make([]*SimilarMovie, 0, len(candidates))
+
+	for other := range candidates {
+		entry := ix.movies[other]
+		results = append(results, &SimilarMovie{ID: entry.id, Title: entry.title, Year: entry.year, Score: similarityScore(movie, entry)})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// similarityScore rates how alike two movies are, from 0 to 1.
+func similarityScore(a, b *similarEntry) float64 {
+	genres := jaccard(a.genres, b.genres)
+	year := math.Max(0, 1-math.Abs(float64(a.year-b.year))/similarYearRange)
+	runtime := math.Max(0, 1-math.Abs(float64(a.runtime-b.runtime))/similarRuntimeRange)
+
+	score := similarGenreWeight*genres + similarYearWeight*year + similarRuntimeWeight*runtime
+	total := similarGenreWeight + similarYearWeight + similarRuntimeWeight
+
+	if len(a.raters) > 0 && len(b.raters) > 0 {
+		score += similarRatingWeight * jaccard(a.raters, b.raters)
+		total += similarRatingWeight
+	}
+
+	// Round so that scores read sensibly and near-ties are broken by id.
+	return math.Round(score/total*1000) / 1000
+}
+
+// jaccard is the size of the intersection of two sets divided by the size of their
+// union.
+func jaccard[K comparable](a, b map[K]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for k := range a {
+		if b[k] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// similarMoviesHandler recommends movies like the one in the URL.
+func (app *application) similarMoviesHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	limit := app.readInt(r.URL.Query(), "limit", 10, v)
+
+	v.CheckCode(limit >= 1 && limit <= 50, "limit", validator.CodeOutOfRange, "must be between 1 and 50", validator.Params{"min": 1, "max": 50})
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.similar.ensureLoaded()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	movies, err := app.similar.similar(id, limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+// maxStatsCacheEntries bounds the number of date ranges statsCache holds at once.
+const maxStatsCacheEntries = 100
+
+// statsCache keeps recently computed statistics for a short while, keyed by date range,
+// so a busy dashboard doesn't rerun the aggregates on every request.
+type statsCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]statsCacheEntry
+}
+
+type statsCacheEntry struct {
+	stats   *data.MovieStats
+	expires time.Time
+}
+
+func newStatsCache(ttl time.Duration) *statsCache {
+	return &statsCache{ttl: ttl, entries: make(map[string]statsCacheEntry)}
+}
+
+// get returns the cached statistics for key, if there are any that haven't expired.
+func (c *statsCache) get(key string) (*data.MovieStats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+
+	return entry.stats, true
+}
+
+func (c *statsCache) set(key string, stats *data.MovieStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	// Make room by dropping the expired entries, or everything if that isn't enough.
+	if len(c.entries) >= maxStatsCacheEntries {
+		for key, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= maxStatsCacheEntries {
+			c.entries = make(map[string]statsCacheEntry)
+		}
+	}
+
+	c.entries[key] = statsCacheEntry{stats: stats, expires: now.Add(c.ttl)}
+}
+
+// movieStatsHandler reports statistics on the catalogue, optionally limited to the
+// movies added within a date range.
+func (app *application) movieStatsHandler(w http.ResponseWriter, r *http.Request) {
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	after := app.readTime(qs, "created_after", v)
+	before := app.readTime(qs, "created_before", v)
+
+	if data.ValidateCreatedRange(v, after, before); !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	key := after.UTC().Format(time.RFC3339) + "/" + before.UTC().Format(time.RFC3339)
+
+	stats, ok := app.stats.get(key)
+	if !ok {
+		var err error
+
+		stats, err = app.models.Stats.Movies(after, before)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		app.stats.set(key, stats)
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"stats": stats}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+// minSuggestSimilarity is the trigram similarity a title needs to be suggested when the
+// query isn't a prefix of it. It's the pg_trgm default, as used by the fuzzy search.
+const minSuggestSimilarity = 0.3
+
+// suggestion is a cached title, along with the forms of it used for matching.
+type suggestion struct {
+	*data.MovieTitle
+	lower    string
+	trigrams int // the number of distinct trigrams in the title
+}
+
+// titleWord is one word of a cached title.
+type titleWord struct {
+	word  string
+	title *suggestion
+}
+
+// suggestIndex holds the cached titles, indexed so that a lookup only touches the titles
+// that can match rather than every one of them.
+type suggestIndex struct {
+	titles   []*suggestion            // sorted by their lower case title
+	words    []titleWord              // every word of every title, sorted by word
+	trigrams map[string][]*suggestion // the titles each trigram appears in
+}
+
+// newSuggestIndex builds the index for the titles.
+func newSuggestIndex(movies []*data.MovieTitle) *suggestIndex {
+	index := &suggestIndex{
+		titles:   make([]*suggestion, len(movies)),
+		trigrams: make(map[string][]*suggestion),
+	}
+
+	for i, movie := range movies {
+		lower := strings.ToLower(movie.Title)
+		title := &suggestion{MovieTitle: movie, lower: lower}
+
+		for _, word := range strings.FieldsFunc(lower, notAlphanumeric) {
+			index.words = append(index.words, titleWord{word: word, title: title})
+		}
+
+		set := trigrams(lower)
+		for trigram := range set {
+			index.trigrams[trigram] = append(index.trigrams[trigram], title)
+		}
+		title.trigrams = len(set)
+
+		index.titles[i] = title
+	}
+
+	sort.Slice(index.titles, func(i, j int) bool { return index.titles[i].lower < index.titles[j].lower })
+	sort.Slice(index.words, func(i, j int) bool { return index.words[i].word < index.words[j].word })
+
+	return index
+}
+
+// withPrefix calls fn for each title starting with prefix.
+func (index *suggestIndex) withPrefix(prefix string, fn func(title *suggestion)) {
+	i := sort.Search(len(index.titles), func(i int) bool { return index.titles[i].lower >= prefix })
+	for ; i < len(index.titles) && strings.HasPrefix(index.titles[i].lower, prefix); i++ {
+		fn(index.titles[i])
+	}
+}
+
+// withWordPrefix calls fn for each title with a word starting with prefix, once for
+// every such word.
+func (index *suggestIndex) withWordPrefix(prefix string, fn func(title *suggestion)) {
+	i := sort.Search(len(index.words), func(i int) bool { return index.words[i].word >= prefix })
+	for ; i < len(index.words) && strings.HasPrefix(index.words[i].word, prefix); i++ {
+		fn(index.words[i].title)
+	}
+}
+
+// similar returns the titles sharing trigrams with qTrigrams, with their similarity to
+// it: the number of trigrams they share divided by the number they have between them,
+// as pg_trgm's similarity().
+func (index *suggestIndex) similar(qTrigrams map[string]bool) map[*suggestion]float64 {
+	shared := make(map[*suggestion]int)
+	for trigram := range qTrigrams {
+		for _, title := range index.trigrams[trigram] {
+			shared[title]++
+		}
+	}
+
+	scores := make(map[*suggestion]float64, len(shared))
+	for title, n := range shared {
+		scores[title] = float64(n) / float64(len(qTrigrams)+title.trigrams-n)
+	}
+
+	return scores
+}
+
+// titleSuggester serves type-ahead suggestions from an in-memory copy of every title,
+// so a keystroke never waits on the database. Movie writes call invalidate, and the
+// copy is also refreshed once it's older than maxAge to pick up changes made outside
+// the API, such as imports. Refreshes happen in the background: until one finishes,
+// requests are served from the previous copy.
+type titleSuggester struct {
+	load   func() ([]*data.MovieTitle, error)
+	maxAge time.Duration
+	logger *log.Logger
+
+	mu       sync.RWMutex
+	index    *suggestIndex
+	loadedAt time.Time
+
+	stale      atomic.Bool
+	refreshing atomic.Bool
+}
+
+func newTitleSuggester(load func() ([]*data.MovieTitle, error), maxAge time.Duration, logger *log.Logger) *titleSuggester {
+	s := &titleSuggester{load: load, maxAge: maxAge, logger: logger, index: newSuggestIndex(nil)}
+	s.stale.Store(true)
+	return s
+}
+
+// invalidate marks the cached titles out of date, so the next request refreshes them.
+func (s *titleSuggester) invalidate() {
+	s.stale.Store(true)
+}
+
+// snapshot returns the cached titles, starting a background refresh if they're out of
+// date and one isn't already running.
+func (s *titleSuggester) snapshot() *suggestIndex {
+	s.mu.RLock()
+	index, loadedAt := s.index, s.loadedAt
+	s.mu.RUnlock()
+
+	if (s.stale.Load() || time.Since(loadedAt) > s.maxAge) && s.refreshing.CompareAndSwap(false, true) {
+		go s.refresh()
+	}
+
+	return index
+}
+
+// refresh reloads the titles from the database and rebuilds the index.
+func (s *titleSuggester) refresh() {
+	defer s.refreshing.Store(false)
+
+	// Clear the flag before loading, so a write made while the load is running
+	// triggers another refresh.
+	s.stale.Store(false)
+
+	movies, err := s.load()
+	if err != nil {
+		s.stale.Store(true)
+		s.logger.Printf("refreshing title suggestions: %v", err)
+		return
+	}
+
+	index := newSuggestIndex(movies)
+
+	s.mu.Lock()
+	s.index, s.loadedAt = index, time.Now()
+	s.mu.Unlock()
+}
+
+// suggest returns up to limit titles matching q. Titles starting with q come first,
+// then titles with a word starting with q, both shortest first, and then titles that are
+// spelt similarly to q, most similar first.
+func (s *titleSuggester) suggest(q string, limit int) []*data.MovieTitle {
+	q = strings.ToLower(strings.TrimSpace(q))
+	index := s.snapshot()
+
+	type match struct {
+		title *suggestion
+		rank  int
+		score float64
+	}
+
+	var matches []match
+	seen := make(map[*suggestion]bool)
+
+	add := func(title *suggestion, rank int, score float64) {
+		if !seen[title] {
+			seen[title] = true
+			matches = append(matches, match{title: title, rank: rank, score: score})
+		}
+	}
+
+	index.withPrefix(q, func(title *suggestion) { add(title, 0, 0) })
+	index.withWordPrefix(q, func(title *suggestion) { add(title, 1, 0) })
+
+	for title, score := range index.similar(trigrams(q)) {
+		if score >= minSuggestSimilarity {
+			add(title, 2, score)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		switch {
+		case a.rank != b.rank:
+			return a.rank < b.rank
+		case a.score != b.score:
+			return a.score > b.score
+		case len(a.title.lower) != len(b.title.lower):
+			return len(a.title.lower) < len(b.title.lower)
+		default:
+			return a.title.ID < b.title.ID
+		}
+	})
+
+	results := make([]*data.MovieTitle, 0, min(limit, len(matches)))
+	for i := 0; i < len(matches) && i < limit; i++ {
+		results = append(results, matches[i].title.MovieTitle)
+	}
+
+	return results
+}
+
+func notAlphanumeric(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// trigrams splits lower case text into trigrams the way pg_trgm does: each word is
+// padded with two spaces in front and one behind.
+func trigrams(s string) map[string]bool {
+	set := make(map[string]bool)
+
+	for _, word := range strings.FieldsFunc(s, notAlphanumeric) {
+		runes := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			set[string(runes[i:i+3])] = true
+		}
+	}
+
+	return set
+}
+
+// suggestMoviesHandler returns titles for a search box's type-ahead.
+func (app *application) suggestMoviesHandler(w http.ResponseWriter, r *http.Request) {
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	q := app.readString(qs, "q", "")
+	limit := app.readInt(qs, "limit", 10, v)
+
+	v.CheckCode(strings.TrimSpace(q) != "", "q", validator.CodeRequired, "must be provided", nil)
+	v.CheckCode(utf8.RuneCountInString(q) <= 100, "q", validator.CodeMaxLength, "must not be more than 100 characters long", validator.Params{"max": 100})
+	v.CheckCode(limit >= 1 && limit <= 20, "limit", validator.CodeOutOfRange, "must be between 1 and 20", validator.Params{"min": 1, "max": 20})
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"suggestions": app.suggestions.suggest(q, limit)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+// runGrant gives a user permissions, such as reviews:moderate, or takes them away with
+// -revoke. There's deliberately no API endpoint for this.
+func runGrant(logger *log.Logger, args []string) error {
+	fs := flag.NewFlagSet("grant", flag.ExitOnError)
+
+	dsn := dbFlags(fs)
+	revoke := fs.Bool("revoke", false, "Remove the permissions instead of adding them")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: zeliz-admin grant [flags] EMAIL PERMISSION...\n\n")
+		fmt.Fprintf(fs.Output(), "Permissions: %s, %s, %s, %s\n\n", data.PermissionModerateReviews, data.PermissionManageGenres, data.PermissionManageMovies, data.PermissionManagePeople)
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	email, codes := fs.Arg(0), fs.Args()[1:]
+
+	db, err := openDB(*dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	models := data.NewModels(db)
+
+	known, err := models.Permissions.Codes()
+	if err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		if !validator.In(code, known...) {
+			return fmt.Errorf("unknown permission %q", code)
+		}
+	}
+
+	user, err := models.Users.GetByEmail(email)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return fmt.Errorf("no user with email %s", email)
+		}
+		return err
+	}
+
+	if *revoke {
+		err = models.Permissions.RemoveForUser(user.ID, codes...)
+	} else {
+		err = models.Permissions.AddForUser(user.ID, codes...)
+	}
+	if err != nil {
+		return err
+	}
+
+	permissions, err := models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("%s now has permissions %v", user.Email, permissions)
+	return nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+// imdbNull is how the IMDb datasets write a missing value.
+const imdbNull = `\N`
+
+// imdbCounts tallies what happened to the rows of an IMDb import.
+type imdbCounts struct {
+	read, inserted, updated, unchanged, trashed, invalid, filtered int
+}
+
+func (c imdbCounts) skipped() int {
+	return c.unchanged + c.trashed + c.invalid + c.filtered
+}
+
+func runIMDb(logger *log.Logger, args []string) error {
+	fs := flag.NewFlagSet("imdb", flag.ExitOnError)
+
+	dsn := dbFlags(fs)
+	types := fs.String("types", "movie", "Comma separated titleType values to import")
+	batchSize := fs.Int("batch-size", 500, "Number of rows upserted per transaction")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: zeliz-admin imdb [flags] title.basics.tsv[.gz]\n\n")
+		fmt.Fprintf(fs.Output(), "Upserts titles from the IMDb title.basics dataset, keyed on their tconst.\n\n")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *batchSize < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	wanted := make(map[string]bool)
+	for _, t := range strings.Split(*types, ",") {
+		wanted[strings.TrimSpace(t)] = true
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(fs.Arg(0), ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	db, err := openDB(*dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	models := data.NewModels(db)
+
+	genres, err := models.Genres.Vocabulary()
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("%s is empty", fs.Arg(0))
+	}
+
+	columns := make(map[string]int)
+	for i, name := range strings.Split(scanner.Text(), "\t") {
+		columns[name] = i
+	}
+
+	for _, name := range []string{"tconst", "titleType", "primaryTitle", "startYear", "runtimeMinutes", "genres"} {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("header is missing the %q column, is this title.basics.tsv?", name)
+		}
+	}
+
+	var (
+		counts  imdbCounts
+		tx      *data.MovieTx
+		pending int
+	)
+
+	// Rows are upserted in batches, each in its own transaction.
+	commit := func() error {
+		if tx == nil {
+			return nil
+		}
+		err := tx.Commit()
+		tx, pending = nil, 0
+		return err
+	}
+
+	defer func() {
+		if tx != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for scanner.Scan() {
+		counts.read++
+
+		fields := strings.Split(scanner.Text(), "\t")
+		field := func(name string) string {
+			i := columns[name]
+			if i >= len(fields) || fields[i] == imdbNull {
+				return ""
+			}
+			return fields[i]
+		}
+
+		if !wanted[field("titleType")] {
+			counts.filtered++
+			continue
+		}
+
+		tconst := field("tconst")
+		movie := imdbMovie(field)
+
+		v := validator.New()
+		v.Check(tconst != "", "tconst", "must be provided")
+
+		if data.ValidateMovie(v, movie); !v.Valid() {
+			counts.invalid++
+			continue
+		}
+
+		// IMDb's genres mostly match our slugs already; "Sci-Fi" goes through its alias.
+		if movie.Genres = genres.Normalise(v, movie.Genres); !v.Valid() {
+			counts.invalid++
+			continue
+		}
+
+		if tx == nil {
+			tx, err = models.Movies.Begin()
+			if err != nil {
+				return err
+			}
+		}
+
+		outcome, err := tx.UpsertByExternalID(data.ExternalSourceIMDb, tconst, movie)
+		if err != nil {
+			return fmt.Errorf("upserting %s: %w", tconst, err)
+		}
+
+		switch outcome {
+		case data.UpsertInserted:
+			counts.inserted++
+		case data.UpsertUpdated:
+			counts.updated++
+		case data.UpsertUnchanged:
+			counts.unchanged++
+		case data.UpsertTrashed:
+			counts.trashed++
+		}
+
+		if pending++; pending >= *batchSize {
+			if err := commit(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if err := commit(); err != nil {
+		return err
+	}
+
+	logger.Printf("read %d rows: inserted %d, updated %d, skipped %d (%d unchanged, %d in the trash, %d invalid, %d other title types)",
+		counts.read, counts.inserted, counts.updated, counts.skipped(), counts.unchanged, counts.trashed, counts.invalid, counts.filtered)
+
+	return nil
+}
+
+// imdbMovie maps a title.basics row onto a Movie. Missing or malformed numbers are left
+// as zero for ValidateMovie to reject. Genres are mapped to slugs by the caller.
+func imdbMovie(field func(name string) string) *data.Movie {
+	movie := &data.Movie{
+		Title:  field("primaryTitle"),
+		Genres: []string{},
+	}
+
+	if year, err := strconv.ParseInt(field("startYear"), 10, 32); err == nil {
+		movie.Year = int32(year)
+	}
+
+	if runtime, err := strconv.ParseInt(field("runtimeMinutes"), 10, 32); err == nil {
+		movie.Runtime = data.Runtime(runtime)
+	}
+
+	if genres := field("genres"); genres != "" {
+		for _, genre := range strings.Split(genres, ",") {
+			movie.Genres = append(movie.Genres, genre)
+		}
+	}
+
+	return movie
+}
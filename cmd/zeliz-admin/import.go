@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+// rowReader yields movies from an import file one row at a time. Next returns io.EOF
+// once the file is exhausted. Problems with an individual row are recorded in v rather
+// than returned, so the import can carry on with the next row.
+type rowReader interface {
+	Next(v *validator.Validator) (line int, movie *data.Movie, err error)
+}
+
+func runImport(logger *log.Logger, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+
+	dsn := dbFlags(fs)
+	format := fs.String("format", "", "Input format (csv|ndjson), defaults to the file extension")
+	dryRun := fs.Bool("dry-run", false, "Validate the file without writing to the database")
+	rejectPath := fs.String("reject-file", "", "Where to write rejected rows (default FILE.rejects.csv)")
+	batchSize := fs.Int("batch-size", 1000, "Number of rows sent to the database per COPY")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: zeliz-admin import [flags] FILE\n\n")
+		fmt.Fprintf(fs.Output(), "CSV files need a header row with title, year, runtime and genres columns.\n")
+		fmt.Fprintf(fs.Output(), "Genres are separated by commas, semicolons or pipes.\n\n")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *batchSize < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	path := fs.Arg(0)
+
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	if *format == "jsonl" {
+		*format = "ndjson"
+	}
+
+	if *rejectPath == "" {
+		*rejectPath = path + ".rejects.csv"
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var rows rowReader
+
+	switch *format {
+	case "csv":
+		rows, err = newCSVRowReader(f)
+		if err != nil {
+			return err
+		}
+	case "ndjson":
+		rows = newNDJSONRowReader(f)
+	default:
+		return fmt.Errorf("unsupported format %q, expected csv or ndjson", *format)
+	}
+
+	// A dry run never touches the database, so it doesn't need a connection either.
+	// That also means genres aren't checked against the vocabulary in a dry run.
+	var (
+		models data.Models
+		genres *data.GenreVocabulary
+	)
+	if !*dryRun {
+		db, err := openDB(*dsn)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		models = data.NewModels(db)
+
+		genres, err = models.Genres.Vocabulary()
+		if err != nil {
+			return err
+		}
+	}
+
+	rejectFile, err := os.Create(*rejectPath)
+	if err != nil {
+		return err
+	}
+	defer rejectFile.Close()
+
+	rejects := csv.NewWriter(rejectFile)
+	rejects.Write([]string{"line", "field", "code", "message"})
+
+	var (
+		batch                    []*data.Movie
+		read, imported, rejected int
+	)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if !*dryRun {
+			if err := models.Movies.InsertMany(batch); err != nil {
+				return err
+			}
+		}
+		imported += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		v := validator.New()
+
+		line, movie, err := rows.Next(v)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		read++
+
+		if v.Valid() {
+			data.ValidateMovie(v, movie)
+		}
+
+		if v.Valid() && genres != nil {
+			movie.Genres = genres.Normalise(v, movie.Genres)
+		}
+
+		if !v.Valid() {
+			rejected++
+			writeRejects(rejects, line, v)
+			continue
+		}
+
+		batch = append(batch, movie)
+
+		if len(batch) >= *batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	rejects.Flush()
+	if err := rejects.Error(); err != nil {
+		return err
+	}
+
+	verb := "imported"
+	if *dryRun {
+		verb = "would import"
+	}
+	logger.Printf("read %d rows: %s %d, rejected %d", read, verb, imported, rejected)
+
+	if rejected > 0 {
+		logger.Printf("rejected rows written to %s", *rejectPath)
+	}
+
+	return nil
+}
+
+// writeRejects adds one line to the reject file for every failed check on a row.
+func writeRejects(w *csv.Writer, line int, v *validator.Validator) {
+	keys := make([]string, 0, len(v.Details))
+	for key := range v.Details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, fe := range v.Details[key] {
+			w.Write([]string{strconv.Itoa(line), key, fe.Code, fe.Message})
+		}
+	}
+}
+
+// splitGenres splits a spreadsheet genres cell such as "drama, crime" or "drama|crime".
+func splitGenres(s string) []string {
+	genres := []string{}
+
+	for _, genre := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || r == '|' }) {
+		if genre = strings.TrimSpace(genre); genre != "" {
+			genres = append(genres, genre)
+		}
+	}
+
+	return genres
+}
+
+type csvRowReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVRowReader(f io.Reader) (*csvRowReader, error) {
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1 // rows with missing cells are rejected per row, not fatally
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range []string{"title", "year", "runtime", "genres"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV header is missing the %q column", name)
+		}
+	}
+
+	return &csvRowReader{r: r, columns: columns}, nil
+}
+
+func (c *csvRowReader) Next(v *validator.Validator) (int, *data.Movie, error) {
+	record, err := c.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			v.AddError("row", parseErr.Err.Error())
+			return parseErr.StartLine, nil, nil
+		}
+		return 0, nil, err
+	}
+
+	line, _ := c.r.FieldPos(0)
+
+	cell := func(name string) string {
+		i := c.columns[name]
+		if i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	movie := &data.Movie{
+		Title:  cell("title"),
+		Genres: splitGenres(cell("genres")),
+	}
+
+	if s := cell("year"); s != "" {
+		year, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			v.AddCodedError("year", validator.CodeNotInteger, "must be an integer value", nil)
+		}
+		movie.Year = int32(year)
+	}
+
+	if s := cell("runtime"); s != "" {
+		runtime, err := data.ParseRuntime(s)
+		if err != nil {
+			v.AddError("runtime", err.Error())
+		}
+		movie.Runtime = runtime
+	}
+
+	return line, movie, nil
+}
+
+type ndjsonRowReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+func newNDJSONRowReader(f io.Reader) *ndjsonRowReader {
+	return &ndjsonRowReader{r: bufio.NewReader(f)}
+}
+
+func (n *ndjsonRowReader) Next(v *validator.Validator) (int, *data.Movie, error) {
+	for {
+		b, err := n.r.ReadBytes('\n')
+		if err != nil && !(errors.Is(err, io.EOF) && len(b) > 0) {
+			return 0, nil, err
+		}
+
+		n.line++
+
+		b = bytes.TrimSpace(b)
+		if len(b) == 0 {
+			continue
+		}
+
+		var input struct {
+			Title   string       `json:"title"`
+			Year    int32        `json:"year"`
+			Runtime data.Runtime `json:"runtime"`
+			Genres  []string     `json:"genres"`
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+
+		if err := dec.Decode(&input); err != nil {
+			v.AddError("row", err.Error())
+			return n.line, nil, nil
+		}
+
+		movie := &data.Movie{
+			Title:   input.Title,
+			Year:    input.Year,
+			Runtime: input.Runtime,
+			Genres:  input.Genres,
+		}
+
+		return n.line, movie, nil
+	}
+}
@@ -0,0 +1,89 @@
+// Command zeliz-admin runs maintenance tasks against the movie database that don't
+// belong behind the HTTP API, such as bulk loading the catalogue.
+//
+// Usage:
+//
+//	zeliz-admin <command> [flags] [arguments]
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	// postgres driver, registered with database/sql
+	_ "github.com/lib/pq"
+)
+
+// a command is one of the zeliz-admin subcommands
+type command struct {
+	name    string
+	summary string
+	run     func(logger *log.Logger, args []string) error
+}
+
+var commands = []command{
+	{"import", "load movies from a CSV or NDJSON file", runImport},
+	{"imdb", "upsert movies from the IMDb title.basics.tsv dataset", runIMDb},
+	{"grant", "give a user permissions, e.g. to moderate reviews", runGrant},
+	{"purge", "permanently delete movies that have been in the trash too long", runPurge},
+}
+
+func main() {
+	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			err := cmd.run(logger, os.Args[2:])
+			if err != nil {
+				logger.Fatal(err)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: zeliz-admin <command> [flags] [arguments]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'zeliz-admin <command> -h' for the flags of a command.\n")
+}
+
+// dbFlags registers the database connection flags shared by every command, using the
+// same flag name and environment variable as the API server.
+func dbFlags(fs *flag.FlagSet) *string {
+	return fs.String("db-dsn", os.Getenv("GREENLIGHT_DB_DSN"), "PostgreSQL DSN")
+}
+
+func openDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/goddhi/zeliz-movie/internal/data"
+)
+
+// runPurge permanently deletes the movies that have been in the trash for longer than
+// the retention period. It's meant to be run regularly, e.g. daily from cron.
+func runPurge(logger *log.Logger, args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+
+	dsn := dbFlags(fs)
+	retention := fs.Duration("retention", 30*24*time.Hour, "How long deleted movies are kept in the trash")
+
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: zeliz-admin purge [flags]\n\n")
+		fs.PrintDefaults()
+	}
+
+	fs.Parse(args)
+
+	if fs.NArg() != 0 || *retention < 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	db, err := openDB(*dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	models := data.NewModels(db)
+
+	purged, err := models.Movies.Purge(time.Now().Add(-*retention))
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("purged %d movies deleted more than %s ago", purged, *retention)
+	return nil
+}
@@ -5,3 +5,7 @@ go 1.21.6
 require github.com/julienschmidt/httprouter v1.3.0
 
 require github.com/lib/pq v1.10.2
+
+require golang.org/x/crypto v0.9.0
+
+require golang.org/x/time v0.3.0
@@ -0,0 +1,163 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/goddhi/zeliz-movie/internal/validator"
+	"github.com/lib/pq"
+)
+
+// CreditRoles is the controlled vocabulary of roles a person can be credited with.
+var CreditRoles = []string{"director", "writer", "producer", "actor", "composer", "cinematographer", "editor"}
+
+// Credit links a person to a movie in a given role. Character is only used for actors.
+type Credit struct {
+	PersonID     int64  `json:"person_id"`
+	Name         string `json:"name,omitempty"` // the person's name, filled in when reading
+	Role         string `json:"role"`
+	Character    string `json:"character,omitempty"`
+	BillingOrder int32  `json:"billing_order"`
+}
+
+// CreditModel struct type which wraps a sql.DB connection pool.
+type CreditModel struct {
+	DB *sql.DB
+}
+
+// maxCredits caps how many credits a single movie can have.
+const maxCredits = 500
+
+func ValidateCredits(v *validator.Validator, credits []*Credit) {
+	v.CheckCode(len(credits) <= maxCredits, "credits", validator.CodeMaxItems, fmt.Sprintf("must not contain more than %d credits", maxCredits), validator.Params{"max": maxCredits})
+
+	seen := make(map[string]bool, len(credits))
+
+	for i, credit := range credits {
+		key := fmt.Sprintf("credits.%d", i)
+
+		// A null in the list decodes to a nil credit.
+		if credit == nil {
+			v.AddCodedError(key, validator.CodeRequired, "must be provided", nil)
+			continue
+		}
+
+		v.CheckCode(credit.PersonID > 0, key+".person_id", validator.CodeRequired, "must be provided", nil)
+		v.CheckCode(validator.In(credit.Role, CreditRoles...), key+".role", validator.CodeNotPermitted, "invalid role", validator.Params{"allowed": CreditRoles})
+		v.CheckCode(credit.Character == "" || credit.Role == "actor", key+".character", validator.CodeNotPermitted, "is only allowed for actors", nil)
+		v.CheckCode(utf8.RuneCountInString(credit.Character) <= 500, key+".character", validator.CodeMaxLength, "must not be more than 500 characters long", validator.Params{"max": 500})
+		v.CheckCode(credit.BillingOrder >= 0, key+".billing_order", validator.CodeMinValue, "must not be negative", validator.Params{"min": 0})
+
+		identity := fmt.Sprintf("%d/%s/%s", credit.PersonID, credit.Role, credit.Character)
+		v.CheckCode(!seen[identity], key, validator.CodeDuplicate, "duplicates an earlier credit", nil)
+		seen[identity] = true
+	}
+}
+
+// GetForMovies returns the credits for each of the given movies, keyed by movie id and
+// ordered by billing order, using a single query.
+func (m CreditModel) GetForMovies(movieIDs []int64) (map[int64][]*Credit, error) {
+	credits := make(map[int64][]*Credit, len(movieIDs))
+
+	if len(movieIDs) == 0 {
+		return credits, nil
+	}
+
+	query := `
+		SELECT c.movie_id, c.person_id, p.name, c.role, c.character_name, c.billing_order
+		FROM movie_credits c
+		INNER JOIN people p ON p.id = c.person_id
+		WHERE c.movie_id = ANY($1)
+		ORDER BY c.movie_id, c.billing_order, p.name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			movieID int64
+			credit  Credit
+		)
+
+		err := rows.Scan(&movieID, &credit.PersonID, &credit.Name, &credit.Role, &credit.Character, &credit.BillingOrder)
+		if err != nil {
+			return nil, err
+		}
+
+		credits[movieID] = append(credits[movieID], &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+// MissingPeople returns those of the given person ids that don't exist.
+func (m CreditModel) MissingPeople(personIDs []int64) ([]int64, error) {
+	query := `
+		SELECT id
+		FROM unnest($1::bigint[]) AS ids(id)
+		WHERE NOT EXISTS (SELECT 1 FROM people p WHERE p.id = ids.id)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(personIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	missing := []int64{}
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		missing = append(missing, id)
+	}
+
+	return missing, rows.Err()
+}
+
+// Replace swaps all of a movie's credits for the given ones in a single transaction.
+func (m CreditModel) Replace(movieID int64, credits []*Credit) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM movie_credits WHERE movie_id = $1`, movieID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO movie_credits (movie_id, person_id, role, character_name, billing_order)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	for _, credit := range credits {
+		_, err = tx.ExecContext(ctx, query, movieID, credit.PersonID, credit.Role, credit.Character, credit.BillingOrder)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,307 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/goddhi/zeliz-movie/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Sources of external identifiers.
+const (
+	ExternalSourceIMDb     = "imdb"
+	ExternalSourceTMDB     = "tmdb"
+	ExternalSourceWikidata = "wikidata"
+)
+
+// ExternalSources maps each supported source to the format of its identifiers.
+var ExternalSources = map[string]*regexp.Regexp{
+	ExternalSourceIMDb:     regexp.MustCompile(`^tt\d{7,}$`),
+	ExternalSourceTMDB:     regexp.MustCompile(`^[1-9]\d*$`),
+	ExternalSourceWikidata: regexp.MustCompile(`^Q[1-9]\d*$`),
+}
+
+// ErrDuplicateExternalID is wrapped by the *ExternalIDConflictError returned when an
+// identifier is already assigned to a different movie.
+var ErrDuplicateExternalID = errors.New("duplicate external id")
+
+// ExternalIDConflictError says which identifier is already taken, and by which movie
+// when that's known. Trashed movies keep their identifiers, so the movie may be one that
+// GetByExternalID won't return; Trashed says so, so it can be restored or purged.
+type ExternalIDConflictError struct {
+	Source  string
+	Value   string
+	MovieID int64
+	Trashed bool
+}
+
+func (e *ExternalIDConflictError) Error() string {
+	if e.Trashed {
+		return fmt.Sprintf("%s id %q is already assigned to movie %d, which is in the trash", e.Source, e.Value, e.MovieID)
+	}
+	return fmt.Sprintf("%s id %q is already assigned to another movie", e.Source, e.Value)
+}
+
+func (e *ExternalIDConflictError) Unwrap() error {
+	return ErrDuplicateExternalID
+}
+
+// ExternalSourceNames returns the supported sources in a stable order.
+func ExternalSourceNames() []string {
+	names := make([]string, 0, len(ExternalSources))
+	for name := range ExternalSources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateExternalIDs checks that every source is supported and every identifier is in
+// that source's format. Errors are keyed "external_ids.<source>".
+func ValidateExternalIDs(v *validator.Validator, ids map[string]string) {
+	for source, value := range ids {
+		key := "external_ids." + source
+
+		rx, ok := ExternalSources[source]
+		if !ok {
+			v.AddCodedError(key, validator.CodeNotPermitted, "unsupported source", validator.Params{"allowed": ExternalSourceNames()})
+			continue
+		}
+
+		v.CheckCode(validator.Matches(value, rx), key, validator.CodeInvalid, fmt.Sprintf("is not a valid %s id", source), nil)
+	}
+}
+
+// LoadExternalIDs fills in ExternalIDs for each of the movies with a single query. Every
+// movie ends up with a non-nil map, even if it has no identifiers.
+func (m MovieModel) LoadExternalIDs(movies []*Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*Movie, len(movies))
+	ids := make([]int64, 0, len(movies))
+
+	for _, movie := range movies {
+		movie.ExternalIDs = map[string]string{}
+		byID[movie.ID] = movie
+		ids = append(ids, movie.ID)
+	}
+
+	query := `
+		SELECT movie_id, source, value
+		FROM external_ids
+		WHERE movie_id = ANY($1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.conn().QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			movieID       int64
+			source, value string
+		)
+
+		if err := rows.Scan(&movieID, &source, &value); err != nil {
+			return err
+		}
+
+		byID[movieID].ExternalIDs[source] = value
+	}
+
+	return rows.Err()
+}
+
+// syncExternalIDs makes the stored identifiers for a movie match movie.ExternalIDs,
+// removing any sources that aren't in the map. A nil map leaves them alone.
+func (m MovieModel) syncExternalIDs(movie *Movie) error {
+	if movie.ExternalIDs == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	sources := make([]string, 0, len(movie.ExternalIDs))
+	for source := range movie.ExternalIDs {
+		sources = append(sources, source)
+	}
+
+	query := `
+		DELETE FROM external_ids
+		WHERE movie_id = $1 AND NOT (source = ANY($2))`
+
+	_, err := m.conn().ExecContext(ctx, query, movie.ID, pq.Array(sources))
+	if err != nil {
+		return err
+	}
+
+	// Look for an identifier that's already taken before trying to insert it, so the
+	// conflict can say which movie has it; a failed insert would abort the transaction.
+	owner := `
+		SELECT e.movie_id, m.deleted_at IS NOT NULL
+		FROM external_ids e
+		INNER JOIN movies m ON m.id = e.movie_id
+		WHERE e.source = $1 AND e.value = $2 AND e.movie_id <> $3`
+
+	query = `
+		INSERT INTO external_ids (source, value, movie_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (movie_id, source) DO UPDATE SET value = EXCLUDED.value`
+
+	for _, source := range sources {
+		value := movie.ExternalIDs[source]
+
+		conflict := &ExternalIDConflictError{Source: source, Value: value}
+
+		err := m.conn().QueryRowContext(ctx, owner, source, value, movie.ID).Scan(&conflict.MovieID, &conflict.Trashed)
+		switch {
+		case err == nil:
+			return conflict
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+
+		_, err = m.conn().ExecContext(ctx, query, source, value, movie.ID)
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "external_ids_pkey" {
+				return &ExternalIDConflictError{Source: source, Value: value}
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetByExternalID returns the movie an external identifier is assigned to.
+func (m MovieModel) GetByExternalID(source, value string) (*Movie, error) {
+
+	query := `
+		SELECT movie_id
+		FROM external_ids
+		WHERE source = $1 AND value = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int64
+
+	err := m.conn().QueryRowContext(ctx, query, source, value).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return m.Get(id)
+}
+
+// UpsertOutcome reports what UpsertByExternalID did with a movie.
+type UpsertOutcome int
+
+const (
+	UpsertInserted UpsertOutcome = iota
+	UpsertUpdated
+	UpsertUnchanged
+	UpsertTrashed // the linked movie is in the trash, so it was left alone
+)
+
+// UpsertByExternalID stores movie against the external identifier (source, value). A
+// movie already linked to the identifier is updated in place (bumping its version) if
+// any of its fields differ; otherwise the movie is inserted and linked. A linked movie
+// that's in the trash is left there untouched and reported as UpsertTrashed. On return
+// the movie holds the stored id, created_at and version.
+//
+// The lookup and the write aren't atomic on their own, so call this on a MovieTx.
+func (m MovieModel) UpsertByExternalID(source, value string, movie *Movie) (UpsertOutcome, error) {
+
+	query := `
+		SELECT m.id, m.created_at, m.title, m.year, m.runtime, m.genres, m.version, m.deleted_at
+		FROM external_ids e
+		INNER JOIN movies m ON m.id = e.movie_id
+		WHERE e.source = $1 AND e.value = $2
+		FOR UPDATE OF m`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var existing Movie
+
+	err := m.conn().QueryRowContext(ctx, query, source, value).Scan(
+		&existing.ID,
+		&existing.CreateAt,
+		&existing.Title,
+		&existing.Year,
+		&existing.Runtime,
+		pq.Array(&existing.Genres),
+		&existing.Version,
+		&existing.DeletedAt,
+	)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		movie.ExternalIDs = map[string]string{source: value}
+
+		err = m.Insert(movie)
+		if err != nil {
+			return 0, err
+		}
+
+		return UpsertInserted, nil
+
+	case err != nil:
+		return 0, err
+	}
+
+	movie.ID = existing.ID
+	movie.CreateAt = existing.CreateAt
+	movie.Version = existing.Version
+
+	if existing.DeletedAt != nil {
+		return UpsertTrashed, nil
+	}
+
+	if sameMovieFields(&existing, movie) {
+		return UpsertUnchanged, nil
+	}
+
+	// movie.ExternalIDs is left nil so Update doesn't touch the movie's other ids.
+	err = m.Update(movie)
+	if err != nil {
+		return 0, err
+	}
+
+	return UpsertUpdated, nil
+}
+
+// sameMovieFields reports whether two movies have the same client-editable fields.
+func sameMovieFields(a, b *Movie) bool {
+	if a.Title != b.Title || a.Year != b.Year || a.Runtime != b.Runtime || len(a.Genres) != len(b.Genres) {
+		return false
+	}
+
+	for i := range a.Genres {
+		if a.Genres[i] != b.Genres[i] {
+			return false
+		}
+	}
+
+	return true
+}
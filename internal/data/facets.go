@@ -0,0 +1,206 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Facets that can be counted alongside a movie listing.
+const (
+	FacetGenres  = "genres"
+	FacetDecade  = "decade"
+	FacetRuntime = "runtime"
+)
+
+// FacetNames lists every facet.
+var FacetNames = []string{FacetGenres, FacetDecade, FacetRuntime}
+
+// runtimeBuckets are the labels of the runtime facet's buckets, split at
+// runtimeBoundaries minutes.
+var (
+	runtimeBoundaries = []int64{90, 120, 150}
+	runtimeBuckets    = []string{"<90", "90-119", "120-149", "150+"}
+)
+
+// FacetBucket is the number of matching movies with a given value of a facet.
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// GetAllWithFacets returns a page of movies like GetAll, together with counts of all the
+// movies matching the filter for each of the named facets. The page and the counts are
+// read in one snapshot, so they always agree with each other.
+func (m MovieModel) GetAllWithFacets(filter MovieFilter, filters Filters, facets []string) ([]*Movie, map[string][]*FacetBucket, error) {
+	var (
+		movies []*Movie
+		counts map[string][]*FacetBucket
+	)
+
+	err := m.withSnapshot(func(m MovieModel) error {
+		var err error
+
+		movies, err = m.GetAll(filter, filters)
+		if err != nil {
+			return err
+		}
+
+		counts, err = m.Facets(filter, facets)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return movies, counts, nil
+}
+
+// Facets counts the movies matching the filter for each of the named facets. Genres are
+// listed most common first and decades in order, leaving out values no movie has; every
+// runtime bucket is listed, even if it's empty.
+func (m MovieModel) Facets(filter MovieFilter, facets []string) (map[string][]*FacetBucket, error) {
+	var counts map[string][]*FacetBucket
+
+	err := m.withFilter(filter, func(m MovieModel) error {
+		var err error
+		counts, err = m.facets(filter, facets)
+		return err
+	})
+
+	return counts, err
+}
+
+func (m MovieModel) facets(filter MovieFilter, facets []string) (map[string][]*FacetBucket, error) {
+	where, args, _ := filter.where()
+
+	counts := make(map[string][]*FacetBucket, len(facets))
+
+	for _, facet := range facets {
+		switch facet {
+		case FacetGenres:
+			query := `
+				SELECT g.genre, count(*)
+				FROM movies
+				CROSS JOIN LATERAL unnest(movies.genres) AS g(genre)
+				` + where + `
+				GROUP BY g.genre
+				ORDER BY count(*) DESC, g.genre`
+
+			buckets, err := m.countFacet(query, args)
+			if err != nil {
+				return nil, err
+			}
+
+			counts[facet] = buckets
+
+		case FacetDecade:
+			query := `
+				SELECT (year / 10) * 10, count(*)
+				FROM movies
+				` + where + `
+				GROUP BY 1
+				ORDER BY 1`
+
+			buckets, err := m.countFacet(query, args)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, bucket := range buckets {
+				bucket.Value += "s"
+			}
+
+			counts[facet] = buckets
+
+		case FacetRuntime:
+			// width_bucket numbers the buckets from 0, below the first boundary.
+			query := fmt.Sprintf(`
+				SELECT width_bucket(runtime, $%d::integer[]), count(*)
+				FROM movies
+				%s
+				GROUP BY 1
+				ORDER BY 1`, len(args)+1, where)
+
+			numbered, err := m.countFacet(query, append(args[:len(args):len(args)], pq.Array(runtimeBoundaries)))
+			if err != nil {
+				return nil, err
+			}
+
+			buckets := make([]*FacetBucket, len(runtimeBuckets))
+			for i, label := range runtimeBuckets {
+				buckets[i] = &FacetBucket{Value: label}
+			}
+
+			for _, bucket := range numbered {
+				i, err := strconv.Atoi(bucket.Value)
+				if err != nil || i < 0 || i >= len(buckets) {
+					return nil, fmt.Errorf("unexpected runtime bucket %q", bucket.Value)
+				}
+				buckets[i].Count = bucket.Count
+			}
+
+			counts[facet] = buckets
+
+		default:
+			return nil, fmt.Errorf("unknown facet %q", facet)
+		}
+	}
+
+	return counts, nil
+}
+
+// countFacet runs a query returning (value, count) rows.
+func (m MovieModel) countFacet(query string, args []interface{}) ([]*FacetBucket, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []*FacetBucket{}
+
+	for rows.Next() {
+		var bucket FacetBucket
+
+		if err := rows.Scan(&bucket.Value, &bucket.Count); err != nil {
+			return nil, err
+		}
+
+		buckets = append(buckets, &bucket)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+// withSnapshot runs fn with a model bound to a read-only transaction that sees the
+// database as it was when the transaction's first query ran. If m is already bound to a
+// transaction, fn just runs as part of it.
+func (m MovieModel) withSnapshot(fn func(m MovieModel) error) error {
+	if m.tx != nil {
+		return fn(m)
+	}
+
+	tx, err := m.DB.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(MovieModel{DB: m.DB, tx: tx, fields: m.fields}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
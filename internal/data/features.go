@@ -0,0 +1,91 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MovieFeatures is what the similar movies index knows about a movie: the fields it's
+// compared on, and the users who have rated it.
+type MovieFeatures struct {
+	ID      int64
+	Title   string
+	Year    int32
+	Runtime int32
+	Genres  []string
+	Raters  []int64
+}
+
+// Features returns the features of every movie, for building the similar movies index.
+// It reads the whole catalogue, so it's given longer than the usual queries.
+func (m MovieModel) Features() ([]*MovieFeatures, error) {
+	query := `
+				SELECT m.id, m.title, m.year, m.runtime, m.genres,
+					coalesce(array_agg(r.user_id) FILTER (WHERE r.user_id IS NOT NULL), '{}')
+				FROM movies m
+				LEFT JOIN ratings r ON r.movie_id = m.id
+				WHERE m.deleted_at IS NULL
+				GROUP BY m.id
+				ORDER BY m.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rows, err := m.conn().QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	features := []*MovieFeatures{}
+
+	for rows.Next() {
+		var f MovieFeatures
+
+		err := rows.Scan(&f.ID, &f.Title, &f.Year, &f.Runtime, pq.Array(&f.Genres), pq.Array(&f.Raters))
+		if err != nil {
+			return nil, err
+		}
+
+		features = append(features, &f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return features, nil
+}
+
+// FeaturesFor returns the features of one movie, for putting it back in the similar
+// movies index when it's restored from the trash.
+func (m MovieModel) FeaturesFor(id int64) (*MovieFeatures, error) {
+	query := `
+				SELECT m.id, m.title, m.year, m.runtime, m.genres,
+					coalesce(array_agg(r.user_id) FILTER (WHERE r.user_id IS NOT NULL), '{}')
+				FROM movies m
+				LEFT JOIN ratings r ON r.movie_id = m.id
+				WHERE m.id = $1 AND m.deleted_at IS NULL
+				GROUP BY m.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var f MovieFeatures
+
+	err := m.conn().QueryRowContext(ctx, query, id).Scan(&f.ID, &f.Title, &f.Year, &f.Runtime, pq.Array(&f.Genres), pq.Array(&f.Raters))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &f, nil
+}
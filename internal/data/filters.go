@@ -1,22 +1,55 @@
-package data
-
-import "github.com/goddhi/zeliz-movie/internal/validator"
-
-type Filters struct {
-	Page          int
-	PageSize      int
-	Sort          string
-	SortStatelist []string
-}
-
-func ValidateFilters(v *validator.Validator, f Filters) {
-
-	v.Check(f.Page > 0, "page", "must be greater than zero")
-	v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
-	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
-	v.Check(f.PageSize <= 100, "page_size", "must be a maixmum of 100")
-
-	// check that the sort parameters matches a value in the SortStatelist
-	v.Check(validator.In(f.Sort, f.SortStatelist...), "sort", "invalid sort value")
-
-}
+package data
+
+import (
+	"strings"
+
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+type Filters struct {
+	Page          int
+	PageSize      int
+	Sort          string
+	SortStatelist []string
+}
+
+func ValidateFilters(v *validator.Validator, f Filters) {
+
+	v.CheckCode(f.Page > 0, "page", validator.CodeMinValue, "must be greater than zero", validator.Params{"min": 1})
+	v.CheckCode(f.Page <= 10_000_000, "page", validator.CodeMaxValue, "must be a maximum of 10 million", validator.Params{"max": 10_000_000})
+	v.CheckCode(f.PageSize > 0, "page_size", validator.CodeMinValue, "must be greater than zero", validator.Params{"min": 1})
+	v.CheckCode(f.PageSize <= 100, "page_size", validator.CodeMaxValue, "must be a maixmum of 100", validator.Params{"max": 100})
+
+	// check that the sort parameters matches a value in the SortStatelist
+	v.CheckCode(validator.In(f.Sort, f.SortStatelist...), "sort", validator.CodeNotPermitted, "invalid sort value", validator.Params{"allowed": f.SortStatelist})
+
+}
+
+// sortColumn returns the column to sort on, with any "-" prefix removed. The value is
+// checked against the SortStatelist again because it ends up in the SQL itself, so this
+// is our last line of defence against injection.
+func (f Filters) sortColumn() string {
+	for _, safeValue := range f.SortStatelist {
+		if f.Sort == safeValue {
+			return strings.TrimPrefix(f.Sort, "-")
+		}
+	}
+
+	panic("unsafe sort parameter: " + f.Sort)
+}
+
+// sortDirection returns "DESC" for a "-" prefixed sort value and "ASC" otherwise.
+func (f Filters) sortDirection() string {
+	if strings.HasPrefix(f.Sort, "-") {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+func (f Filters) limit() int {
+	return f.PageSize
+}
+
+func (f Filters) offset() int {
+	return (f.Page - 1) * f.PageSize
+}
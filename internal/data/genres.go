@@ -0,0 +1,529 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/goddhi/zeliz-movie/internal/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrDuplicateGenre is returned when a slug or alias is already used by a genre.
+	ErrDuplicateGenre = errors.New("duplicate genre")
+	// ErrGenreInUse is returned when deleting a genre that movies still have.
+	ErrGenreInUse = errors.New("genre in use")
+)
+
+// Genre is an entry in the managed genre vocabulary. Movies store genres by slug; the
+// aliases are alternative spellings that are mapped to the slug on the way in.
+type Genre struct {
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	Aliases   []string  `json:"aliases"`
+	CreatedAt time.Time `json:"-"`
+	Version   int32     `json:"version"`
+}
+
+// GenreModel struct type which wraps a sql.DB connection pool.
+type GenreModel struct {
+	DB *sql.DB
+}
+
+// GenreKey normalises a genre name for matching against slugs and aliases: it is
+// lower-cased and every run of characters other than a-z and 0-9 becomes a single
+// hyphen, so "Sci Fi", "sci-fi" and "SCI_FI" all give "sci-fi". The migration
+// that back-filled the genres table uses the same rule.
+func GenreKey(s string) string {
+	var b strings.Builder
+	hyphen := false
+
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+
+	return b.String()
+}
+
+func ValidateGenre(v *validator.Validator, genre *Genre) {
+	v.CheckCode(genre.Slug != "", "slug", validator.CodeRequired, "must be provided", nil)
+	v.CheckCode(genre.Slug == GenreKey(genre.Slug), "slug", validator.CodeInvalid, "must only contain lower case letters, digits and single hyphens", nil)
+	v.CheckCode(utf8.RuneCountInString(genre.Slug) <= 100, "slug", validator.CodeMaxLength, "must not be more than 100 characters long", validator.Params{"max": 100})
+
+	v.CheckCode(genre.Name != "", "name", validator.CodeRequired, "must be provided", nil)
+	v.CheckCode(utf8.RuneCountInString(genre.Name) <= 100, "name", validator.CodeMaxLength, "must not be more than 100 characters long", validator.Params{"max": 100})
+
+	v.CheckCode(len(genre.Aliases) <= 50, "aliases", validator.CodeMaxItems, "must not contain more than 50 aliases", validator.Params{"max": 50})
+	v.CheckCode(validator.Unique(genre.Aliases), "aliases", validator.CodeDuplicate, "must not contain duplicate values", nil)
+
+	for i, alias := range genre.Aliases {
+		key := fmt.Sprintf("aliases.%d", i)
+		v.CheckCode(alias != "", key, validator.CodeRequired, "must be provided", nil)
+		v.CheckCode(alias != genre.Slug, key, validator.CodeDuplicate, "must not be the same as the slug", nil)
+	}
+}
+
+// normaliseAliases turns aliases into keys as they're stored, dropping any that end up
+// empty.
+func normaliseAliases(aliases []string) []string {
+	keys := []string{}
+	for _, alias := range aliases {
+		if key := GenreKey(alias); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (m GenreModel) GetAll() ([]*Genre, error) {
+	query := `
+		SELECT g.slug, g.name, g.created_at, g.version,
+			COALESCE(array_agg(a.alias ORDER BY a.alias) FILTER (WHERE a.alias IS NOT NULL), '{}')
+		FROM genres g
+		LEFT JOIN genre_aliases a ON a.slug = g.slug
+		GROUP BY g.slug
+		ORDER BY g.slug`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := []*Genre{}
+
+	for rows.Next() {
+		var genre Genre
+
+		err := rows.Scan(&genre.Slug, &genre.Name, &genre.CreatedAt, &genre.Version, pq.Array(&genre.Aliases))
+		if err != nil {
+			return nil, err
+		}
+
+		genres = append(genres, &genre)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return genres, nil
+}
+
+func (m GenreModel) Get(slug string) (*Genre, error) {
+	query := `
+		SELECT g.slug, g.name, g.created_at, g.version,
+			COALESCE(array_agg(a.alias ORDER BY a.alias) FILTER (WHERE a.alias IS NOT NULL), '{}')
+		FROM genres g
+		LEFT JOIN genre_aliases a ON a.slug = g.slug
+		WHERE g.slug = $1
+		GROUP BY g.slug`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var genre Genre
+
+	err := m.DB.QueryRowContext(ctx, query, slug).Scan(&genre.Slug, &genre.Name, &genre.CreatedAt, &genre.Version, pq.Array(&genre.Aliases))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &genre, nil
+}
+
+// Insert adds a genre and its aliases. It returns ErrDuplicateGenre if the slug or any
+// alias is already taken by a genre (as either a slug or an alias).
+func (m GenreModel) Insert(genre *Genre) error {
+	genre.Aliases = normaliseAliases(genre.Aliases)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = checkGenreKeysFree(ctx, tx, "", append([]string{genre.Slug}, genre.Aliases...))
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO genres (slug, name)
+		VALUES ($1, $2)
+		RETURNING created_at, version`
+
+	err = tx.QueryRowContext(ctx, query, genre.Slug, genre.Name).Scan(&genre.CreatedAt, &genre.Version)
+	if err != nil {
+		return duplicateGenreError(err)
+	}
+
+	err = insertGenreAliases(ctx, tx, genre.Slug, genre.Aliases)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Update saves a genre's name and replaces its aliases, provided the version hasn't
+// changed since it was read.
+func (m GenreModel) Update(genre *Genre) error {
+	genre.Aliases = normaliseAliases(genre.Aliases)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = checkGenreKeysFree(ctx, tx, genre.Slug, genre.Aliases)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE genres
+		SET name = $1, version = version + 1
+		WHERE slug = $2 AND version = $3
+		RETURNING version`
+
+	err = tx.QueryRowContext(ctx, query, genre.Name, genre.Slug, genre.Version).Scan(&genre.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM genre_aliases WHERE slug = $1`, genre.Slug)
+	if err != nil {
+		return err
+	}
+
+	err = insertGenreAliases(ctx, tx, genre.Slug, genre.Aliases)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a genre and its aliases. Genres that movies still use can't be deleted
+// (ErrGenreInUse); merge them into another genre instead.
+func (m GenreModel) Delete(slug string) error {
+	query := `
+		DELETE FROM genres
+		WHERE slug = $1
+		AND NOT EXISTS (SELECT 1 FROM movies WHERE $1 = ANY(genres))`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, slug)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		// Work out which of the two conditions stopped the delete.
+		_, err := m.Get(slug)
+		if err != nil {
+			return err
+		}
+		return ErrGenreInUse
+	}
+
+	return nil
+}
+
+// Merge folds the genre from into the genre into: every movie tagged with from is
+// re-tagged with into (without duplicating it), from's aliases move across, from itself
+// becomes an alias of into, and from is deleted. It returns the number of movies changed.
+func (m GenreModel) Merge(from, into string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRowContext(ctx, `SELECT count(*) FROM genres WHERE slug IN ($1, $2)`, from, into).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	if count != 2 {
+		return 0, ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE movies
+		SET genres = ARRAY(
+			SELECT n.g
+			FROM (
+				SELECT CASE WHEN u.g = $1 THEN $2 ELSE u.g END AS g, min(u.ord) AS ord
+				FROM unnest(movies.genres) WITH ORDINALITY AS u(g, ord)
+				GROUP BY 1
+			) n
+			ORDER BY n.ord
+		), version = version + 1
+		WHERE $1 = ANY(genres)`
+
+	result, err := tx.ExecContext(ctx, query, from, into)
+	if err != nil {
+		return 0, err
+	}
+
+	moviesChanged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE genre_aliases SET slug = $2 WHERE slug = $1`, from, into)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM genres WHERE slug = $1`, from)
+	if err != nil {
+		return 0, err
+	}
+
+	err = insertGenreAliases(ctx, tx, into, []string{from})
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE genres SET version = version + 1 WHERE slug = $1`, into)
+	if err != nil {
+		return 0, err
+	}
+
+	return moviesChanged, tx.Commit()
+}
+
+// checkGenreKeysFree returns ErrDuplicateGenre if any of keys is already a slug, or an
+// alias of a genre other than owner.
+func checkGenreKeysFree(ctx context.Context, tx *sql.Tx, owner string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT EXISTS (SELECT 1 FROM genres WHERE slug = ANY($1) AND slug <> $2)
+		OR EXISTS (SELECT 1 FROM genre_aliases WHERE alias = ANY($1) AND slug <> $2)`
+
+	var taken bool
+
+	err := tx.QueryRowContext(ctx, query, pq.Array(keys), owner).Scan(&taken)
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		return ErrDuplicateGenre
+	}
+
+	return nil
+}
+
+func insertGenreAliases(ctx context.Context, tx *sql.Tx, slug string, aliases []string) error {
+	query := `
+		INSERT INTO genre_aliases (alias, slug)
+		VALUES ($1, $2)`
+
+	for _, alias := range aliases {
+		_, err := tx.ExecContext(ctx, query, alias, slug)
+		if err != nil {
+			return duplicateGenreError(err)
+		}
+	}
+
+	return nil
+}
+
+// duplicateGenreError turns a unique violation into ErrDuplicateGenre.
+func duplicateGenreError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateGenre
+	}
+	return err
+}
+
+// GenreVocabulary resolves the genre names clients send to slugs.
+type GenreVocabulary struct {
+	slugs   map[string]bool
+	aliases map[string]string
+}
+
+// Vocabulary loads every slug and alias.
+func (m GenreModel) Vocabulary() (*GenreVocabulary, error) {
+	query := `
+		SELECT slug, slug FROM genres
+		UNION ALL
+		SELECT alias, slug FROM genre_aliases`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vocab := &GenreVocabulary{slugs: map[string]bool{}, aliases: map[string]string{}}
+
+	for rows.Next() {
+		var key, slug string
+
+		if err := rows.Scan(&key, &slug); err != nil {
+			return nil, err
+		}
+
+		if key == slug {
+			vocab.slugs[slug] = true
+		} else {
+			vocab.aliases[key] = slug
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return vocab, nil
+}
+
+// Resolve returns the slug for a genre name, and whether it is known at all.
+func (gv *GenreVocabulary) Resolve(name string) (string, bool) {
+	key := GenreKey(name)
+
+	if gv.slugs[key] {
+		return key, true
+	}
+
+	slug, ok := gv.aliases[key]
+	return slug, ok
+}
+
+// Normalise resolves every genre to its slug, dropping repeats that resolve to the same
+// slug. Unknown genres are recorded in v under "genres", with the closest known slug as
+// a suggestion when there is a reasonably close one.
+func (gv *GenreVocabulary) Normalise(v *validator.Validator, genres []string) []string {
+	if genres == nil {
+		return nil
+	}
+
+	slugs := make([]string, 0, len(genres))
+	seen := make(map[string]bool, len(genres))
+
+	for _, genre := range genres {
+		slug, ok := gv.Resolve(genre)
+		if !ok {
+			if suggestion := gv.suggest(genre); suggestion != "" {
+				v.AddCodedError("genres", validator.CodeSuggestion, fmt.Sprintf("unknown genre %q, did you mean %q?", genre, suggestion), validator.Params{"value": genre, "suggestion": suggestion})
+			} else {
+				v.AddCodedError("genres", validator.CodeUnknown, fmt.Sprintf("unknown genre %q", genre), validator.Params{"value": genre})
+			}
+			continue
+		}
+
+		if !seen[slug] {
+			seen[slug] = true
+			slugs = append(slugs, slug)
+		}
+	}
+
+	return slugs
+}
+
+// suggest returns the slug whose slug or alias is nearest to name by edit distance, or
+// "" if nothing is close enough to be a plausible typo.
+func (gv *GenreVocabulary) suggest(name string) string {
+	key := GenreKey(name)
+	if key == "" {
+		return ""
+	}
+
+	best, bestDistance := "", len(key)/3+2
+
+	consider := func(candidate, slug string) {
+		if d := levenshtein(key, candidate); d < bestDistance || (d == bestDistance && slug < best) {
+			best, bestDistance = slug, d
+		}
+	}
+
+	for slug := range gv.slugs {
+		consider(slug, slug)
+	}
+	for alias, slug := range gv.aliases {
+		consider(alias, slug)
+	}
+
+	return best
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,138 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// IdempotencyRecord is a stored Idempotency-Key along with a fingerprint of the request
+// that first used it and, once that request has finished, the response it got.
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint []byte
+	Completed   bool // false while the original request is still being processed
+	StatusCode  int
+	Header      http.Header
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// IdempotencyModel struct type which wraps a sql.DB connection pool.
+type IdempotencyModel struct {
+	DB *sql.DB
+}
+
+// Reserve claims key for a new request. If the key is free (or its previous record has
+// expired) a placeholder is stored for window and Reserve returns true. Otherwise it
+// returns false along with the existing record, so the caller can replay or reject.
+func (m IdempotencyModel) Reserve(key string, fingerprint []byte, window time.Duration) (*IdempotencyRecord, bool, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// Clear out anything past its window first, so an expired key can be reused.
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < NOW()`)
+	if err != nil {
+		return nil, false, err
+	}
+
+	query := `
+		INSERT INTO idempotency_keys (key, fingerprint, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO NOTHING`
+
+	result, err := m.DB.ExecContext(ctx, query, key, fingerprint, time.Now().Add(window))
+	if err != nil {
+		return nil, false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if rowsAffected == 1 {
+		return nil, true, nil
+	}
+
+	query = `
+		SELECT key, fingerprint, status_code, headers, body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE key = $1`
+
+	var (
+		record     IdempotencyRecord
+		statusCode sql.NullInt64
+		headers    []byte
+	)
+
+	err = m.DB.QueryRowContext(ctx, query, key).Scan(
+		&record.Key,
+		&record.Fingerprint,
+		&statusCode,
+		&headers,
+		&record.Body,
+		&record.CreatedAt,
+		&record.ExpiresAt,
+	)
+	if err != nil {
+		switch {
+		// The other request gave the key up between our insert and select.
+		case errors.Is(err, sql.ErrNoRows):
+			return m.Reserve(key, fingerprint, window)
+		default:
+			return nil, false, err
+		}
+	}
+
+	if statusCode.Valid {
+		record.Completed = true
+		record.StatusCode = int(statusCode.Int64)
+
+		if err := json.Unmarshal(headers, &record.Header); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return &record, false, nil
+}
+
+// Complete records the response for a reserved key so that retries can replay it.
+func (m IdempotencyModel) Complete(key string, statusCode int, header http.Header, body []byte) error {
+
+	headers, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $1, headers = $2, body = $3
+		WHERE key = $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, query, statusCode, headers, body, key)
+	return err
+}
+
+// Release removes the placeholder for a key whose request didn't succeed, so the client
+// can retry with the same key.
+func (m IdempotencyModel) Release(key string) error {
+
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE key = $1 AND status_code IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, key)
+	return err
+}
@@ -0,0 +1,469 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/goddhi/zeliz-movie/internal/validator"
+	"github.com/lib/pq"
+)
+
+// List visibilities.
+const (
+	ListPublic  = "public"
+	ListPrivate = "private"
+)
+
+// ListVisibilities lists every visibility.
+var ListVisibilities = []string{ListPublic, ListPrivate}
+
+// MaxListItems caps how many movies a single list can hold.
+const MaxListItems = 1000
+
+var (
+	// ErrDuplicateListItem is returned when adding a movie that's already on the list.
+	ErrDuplicateListItem = errors.New("duplicate list item")
+	// ErrListFull is returned when adding to a list that already has MaxListItems.
+	ErrListFull = errors.New("list full")
+)
+
+// List is a user's ordered list of movies, such as a watchlist.
+type List struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Visibility  string    `json:"visibility"`
+	ItemCount   int32     `json:"item_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Version     int32     `json:"version"`
+}
+
+// ListItem is a movie's place on a list. The title and year are filled in when reading,
+// so a list can be shown without fetching every movie.
+type ListItem struct {
+	Position int32     `json:"position"`
+	MovieID  int64     `json:"movie_id"`
+	Title    string    `json:"title"`
+	Year     int32     `json:"year,omitempty"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
+// ListModel struct type which wraps a sql.DB connection pool.
+type ListModel struct {
+	DB *sql.DB
+}
+
+func ValidateList(v *validator.Validator, list *List) {
+	v.CheckCode(list.Name != "", "name", validator.CodeRequired, "must be provided", nil)
+	v.CheckCode(utf8.RuneCountInString(list.Name) <= 200, "name", validator.CodeMaxLength, "must not be more than 200 characters long", validator.Params{"max": 200})
+	v.CheckCode(utf8.RuneCountInString(list.Description) <= 2000, "description", validator.CodeMaxLength, "must not be more than 2000 characters long", validator.Params{"max": 2000})
+	v.CheckCode(validator.In(list.Visibility, ListVisibilities...), "visibility", validator.CodeNotPermitted, "invalid visibility", validator.Params{"allowed": ListVisibilities})
+}
+
+// VisibleTo reports whether the user can see the list.
+func (list *List) VisibleTo(user *User) bool {
+	return list.Visibility == ListPublic || (!user.IsAnonymous() && list.UserID == user.ID)
+}
+
+// Movies in the trash are hidden from a list, so they aren't counted either.
+const listColumns = `l.id, l.user_id, l.name, l.description, l.visibility, l.created_at, l.updated_at, l.version,
+			(SELECT count(*) FROM list_items i INNER JOIN movies mv ON mv.id = i.movie_id WHERE i.list_id = l.id AND mv.deleted_at IS NULL)`
+
+func scanList(row interface{ Scan(...interface{}) error }) (*List, error) {
+	var list List
+
+	err := row.Scan(
+		&list.ID,
+		&list.UserID,
+		&list.Name,
+		&list.Description,
+		&list.Visibility,
+		&list.CreatedAt,
+		&list.UpdatedAt,
+		&list.Version,
+		&list.ItemCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+func (m ListModel) Insert(list *List) error {
+	query := `
+		INSERT INTO lists (user_id, name, description, visibility)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, list.UserID, list.Name, list.Description, list.Visibility).Scan(
+		&list.ID,
+		&list.CreatedAt,
+		&list.UpdatedAt,
+		&list.Version,
+	)
+}
+
+// Get returns a list without its items; see Items.
+func (m ListModel) Get(id int64) (*List, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + listColumns + `
+		FROM lists l
+		WHERE l.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	list, err := scanList(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
+
+// GetAllForUser returns a page of the user's lists, leaving out private ones unless
+// includePrivate is set.
+func (m ListModel) GetAllForUser(userID int64, includePrivate bool, filters Filters) ([]*List, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM lists l
+		WHERE l.user_id = $1
+		AND (l.visibility = 'public' OR $2)
+		ORDER BY l.%s %s, l.id ASC
+		LIMIT $3 OFFSET $4`, listColumns, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, includePrivate, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []*List{}
+
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		lists = append(lists, list)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+// Update saves a list's name, description and visibility, returning ErrEditConflict if
+// the list has changed since it was read.
+func (m ListModel) Update(list *List) error {
+	query := `
+		UPDATE lists
+		SET name = $1, description = $2, visibility = $3, updated_at = NOW(), version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING updated_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, list.Name, list.Description, list.Visibility, list.ID, list.Version).Scan(&list.UpdatedAt, &list.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ListModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM lists
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Items returns the movies on a list in order.
+func (m ListModel) Items(listID int64) ([]*ListItem, error) {
+	// Positions are numbered on the way out so any gaps left by deleted movies don't show.
+	// Movies in the trash are hidden, and reappear in place if they're restored.
+	query := `
+		SELECT row_number() OVER (ORDER BY i.position), i.movie_id, mv.title, mv.year, i.added_at
+		FROM list_items i
+		INNER JOIN movies mv ON mv.id = i.movie_id
+		WHERE i.list_id = $1 AND mv.deleted_at IS NULL
+		ORDER BY i.position`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*ListItem{}
+
+	for rows.Next() {
+		var item ListItem
+
+		err := rows.Scan(&item.Position, &item.MovieID, &item.Title, &item.Year, &item.AddedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// AddItem puts a movie on a list at the given position, moving the movies at and after
+// it down one. A position of zero, or past the end, adds it to the end.
+func (m ListModel) AddItem(listID, movieID int64, position int32) error {
+	return m.editItems(listID, func(ctx context.Context, tx *sql.Tx, count int32) error {
+		if count >= MaxListItems {
+			return ErrListFull
+		}
+
+		if position < 1 || position > count+1 {
+			position = count + 1
+		}
+
+		stored, err := storedPosition(ctx, tx, listID, position)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE list_items SET position = position + 1 WHERE list_id = $1 AND position >= $2`, listID, stored)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO list_items (list_id, movie_id, position)
+			VALUES ($1, $2, $3)`
+
+		_, err = tx.ExecContext(ctx, query, listID, movieID, stored)
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "list_items_pkey" {
+				return ErrDuplicateListItem
+			}
+			return err
+		}
+
+		return nil
+	})
+}
+
+// MoveItem moves a movie to a new position on a list, shifting the movies in between
+// up or down one. A position past the end moves it to the end. It returns
+// ErrRecordNotFound if the movie isn't on the list, or is hidden from it in the trash.
+func (m ListModel) MoveItem(listID, movieID int64, position int32) error {
+	return m.editItems(listID, func(ctx context.Context, tx *sql.Tx, count int32) error {
+		var current int32
+
+		query := `
+			SELECT i.position
+			FROM list_items i
+			INNER JOIN movies mv ON mv.id = i.movie_id
+			WHERE i.list_id = $1 AND i.movie_id = $2 AND mv.deleted_at IS NULL`
+
+		err := tx.QueryRowContext(ctx, query, listID, movieID).Scan(&current)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+
+		if position < 1 {
+			position = 1
+		}
+		if position > count {
+			position = count
+		}
+
+		position, err = storedPosition(ctx, tx, listID, position)
+		if err != nil {
+			return err
+		}
+
+		query = `
+			UPDATE list_items
+			SET position = CASE
+				WHEN movie_id = $2 THEN $4::integer
+				WHEN $4::integer < $3::integer THEN position + 1
+				ELSE position - 1
+			END
+			WHERE list_id = $1
+			AND position BETWEEN LEAST($3::integer, $4::integer) AND GREATEST($3::integer, $4::integer)`
+
+		_, err = tx.ExecContext(ctx, query, listID, movieID, current, position)
+		return err
+	})
+}
+
+// RemoveItem takes a movie off a list, closing up the gap. It returns ErrRecordNotFound
+// if the movie isn't on the list.
+func (m ListModel) RemoveItem(listID, movieID int64) error {
+	return m.editItems(listID, func(ctx context.Context, tx *sql.Tx, count int32) error {
+		var position int32
+
+		err := tx.QueryRowContext(ctx, `DELETE FROM list_items WHERE list_id = $1 AND movie_id = $2 RETURNING position`, listID, movieID).Scan(&position)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE list_items SET position = position - 1 WHERE list_id = $1 AND position > $2`, listID, position)
+		return err
+	})
+}
+
+// editItems runs fn in a transaction holding a lock on the list, so concurrent changes
+// to the same list take turns. Before fn runs the positions are renumbered from 1 to
+// close any gaps, and fn is given the number of items that are shown, leaving out
+// movies in the trash. The list's updated_at is bumped afterwards.
+func (m ListModel) editItems(listID int64, fn func(ctx context.Context, tx *sql.Tx, count int32) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `SELECT id FROM lists WHERE id = $1 FOR NO KEY UPDATE`, listID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE list_items
+		SET position = n.position
+		FROM (
+			SELECT movie_id, row_number() OVER (ORDER BY position) AS position
+			FROM list_items
+			WHERE list_id = $1
+		) n
+		WHERE list_items.list_id = $1 AND list_items.movie_id = n.movie_id
+		AND list_items.position <> n.position`
+
+	_, err = tx.ExecContext(ctx, query, listID)
+	if err != nil {
+		return err
+	}
+
+	var count int32
+
+	query = `
+		SELECT count(*)
+		FROM list_items i
+		INNER JOIN movies mv ON mv.id = i.movie_id
+		WHERE i.list_id = $1 AND mv.deleted_at IS NULL`
+
+	err = tx.QueryRowContext(ctx, query, listID).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	err = fn(ctx, tx, count)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE lists SET updated_at = NOW() WHERE id = $1`, listID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// storedPosition converts a position as shown by Items into the stored position of the
+// item there. The two differ once a movie before it is in the trash, since trashed movies
+// keep their place so they reappear in it if restored. A position past the last shown
+// item converts to the one after the last stored item.
+func storedPosition(ctx context.Context, tx *sql.Tx, listID int64, position int32) (int32, error) {
+	query := `
+		SELECT coalesce(
+			(SELECT i.position
+			FROM list_items i
+			INNER JOIN movies mv ON mv.id = i.movie_id
+			WHERE i.list_id = $1 AND mv.deleted_at IS NULL
+			ORDER BY i.position
+			OFFSET ($2::integer - 1) LIMIT 1),
+			(SELECT coalesce(max(position), 0) + 1 FROM list_items WHERE list_id = $1))`
+
+	var stored int32
+
+	err := tx.QueryRowContext(ctx, query, listID, position).Scan(&stored)
+	if err != nil {
+		return 0, err
+	}
+
+	return stored, nil
+}
@@ -1,27 +1,49 @@
-package data
-
-import (
-	"database/sql"
-	"errors"
-)
-
-//return this from our Get() method when
-// looking up a movie that doesn't exist in our database.
-var (
-	ErrRecordNotFound = errors.New("record not found")
-	ErrEditConflict  = errors.New("edit conflict")
-)
-
-//Models struct which wraps the MovieModel. We'll add other models to this,
-// like a UserModel and PermissionModel, as our build progresses.
-type Models struct {
-	Movies MovieModel
-}
-
-// For ease of use, NewModels() method which returns a Models struct containing
-// the initialized MovieModel
-func NewModels(db *sql.DB) Models {
-	return Models{
-		Movies: MovieModel{DB: db},
-	}
+package data
+
+import (
+	"database/sql"
+	"errors"
+)
+
+//return this from our Get() method when
+// looking up a movie that doesn't exist in our database.
+var (
+	ErrRecordNotFound = errors.New("record not found")
+	ErrEditConflict  = errors.New("edit conflict")
+)
+
+//Models struct which wraps the MovieModel. We'll add other models to this,
+// like a UserModel and PermissionModel, as our build progresses.
+type Models struct {
+	Movies MovieModel
+	Idempotency IdempotencyModel
+	People PersonModel
+	Credits CreditModel
+	Genres GenreModel
+	Users UserModel
+	Tokens TokenModel
+	Ratings RatingModel
+	Permissions PermissionModel
+	Reviews ReviewModel
+	Lists ListModel
+	Stats StatsModel
+}
+
+// For ease of use, NewModels() method which returns a Models struct containing
+// the initialized MovieModel
+func NewModels(db *sql.DB) Models {
+	return Models{
+		Movies: MovieModel{DB: db},
+		Idempotency: IdempotencyModel{DB: db},
+		People: PersonModel{DB: db},
+		Credits: CreditModel{DB: db},
+		Genres: GenreModel{DB: db},
+		Users: UserModel{DB: db},
+		Tokens: TokenModel{DB: db},
+		Ratings: RatingModel{DB: db},
+		Permissions: PermissionModel{DB: db},
+		Reviews: ReviewModel{DB: db},
+		Lists: ListModel{DB: db},
+		Stats: StatsModel{DB: db},
+	}
 }
\ No newline at end of file
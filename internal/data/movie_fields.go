@@ -0,0 +1,123 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// MovieFieldNames lists the fields of a movie a client can ask for with ?fields=, by
+// their JSON names.
+var MovieFieldNames = []string{"id", "title", "year", "runtime", "genres", "external_ids", "average_rating", "rating_count", "highlight", "version"}
+
+// movieColumns are the columns of the movies table a Movie is read from, in the order
+// they're selected, with the field each one fills.
+var movieColumns = []struct {
+	field  string
+	column string
+	dest   func(movie *Movie) interface{}
+}{
+	{"id", "id", func(movie *Movie) interface{} { return &movie.ID }},
+	{"", "created_at", func(movie *Movie) interface{} { return &movie.CreateAt }},
+	{"title", "title", func(movie *Movie) interface{} { return &movie.Title }},
+	{"year", "year", func(movie *Movie) interface{} { return &movie.Year }},
+	{"runtime", "runtime", func(movie *Movie) interface{} { return &movie.Runtime }},
+	{"genres", "genres", func(movie *Movie) interface{} { return pq.Array(&movie.Genres) }},
+	{"average_rating", "average_rating", func(movie *Movie) interface{} { return &movie.AverageRating }},
+	{"rating_count", "rating_count", func(movie *Movie) interface{} { return &movie.RatingCount }},
+	{"version", "version", func(movie *Movie) interface{} { return &movie.Version }},
+	{"", "deleted_at", func(movie *Movie) interface{} { return &movie.DeletedAt }},
+}
+
+// Select returns a copy of the model whose Get and GetAll only read the given fields,
+// and whose movies only render those fields as JSON. Only columns that are needed are
+// selected, and external ids and search highlights are only worked out if they're
+// asked for. A nil slice selects every field.
+func (m MovieModel) Select(fields []string) MovieModel {
+	m.fields = fields
+	return m
+}
+
+// selects reports whether the model reads the named field.
+func (m MovieModel) selects(field string) bool {
+	if m.fields == nil {
+		return true
+	}
+
+	for _, f := range m.fields {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// columns returns the column list for reading movies, and the values to scan a row of
+// them into. The id is always read, since related data is looked up by it.
+func (m MovieModel) columns() (string, func(movie *Movie) []interface{}) {
+	var names []string
+	var dests []func(movie *Movie) interface{}
+
+	for _, c := range movieColumns {
+		// created_at and deleted_at can't be asked for, so they're only read along with
+		// everything else.
+		if c.field == "id" || (c.field == "" && m.fields == nil) || (c.field != "" && m.selects(c.field)) {
+			names = append(names, c.column)
+			dests = append(dests, c.dest)
+		}
+	}
+
+	return strings.Join(names, ", "), func(movie *Movie) []interface{} {
+		movie.fields = m.fields
+
+		values := make([]interface{}, len(dests))
+		for i, dest := range dests {
+			values[i] = dest(movie)
+		}
+		return values
+	}
+}
+
+// movieIncludable are the fields of a movie holding related data, which pick keeps
+// whenever they're loaded, since they're only loaded when a client asks for them.
+var movieIncludable = []string{"credits", "external_ids", "rating_summary"}
+
+// pick cuts the JSON object for a movie down to its selected fields, in the order they
+// were asked for, followed by any related data that was loaded.
+func (movie Movie) pick(full []byte) ([]byte, error) {
+	var all map[string]json.RawMessage
+
+	err := json.Unmarshal(full, &all)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	written := make(map[string]bool, len(movie.fields))
+
+	for _, field := range append(movie.fields[:len(movie.fields):len(movie.fields)], movieIncludable...) {
+		value, ok := all[field]
+		if !ok || written[field] {
+			continue
+		}
+		written[field] = true
+
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(field)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,287 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goddhi/zeliz-movie/internal/filter"
+	"github.com/goddhi/zeliz-movie/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Ways of matching the genres filter against a movie's genres.
+const (
+	GenreMatchAll  = "all"
+	GenreMatchAny  = "any"
+	GenreMatchNone = "none"
+)
+
+// GenreMatches lists every genre match mode.
+var GenreMatches = []string{GenreMatchAll, GenreMatchAny, GenreMatchNone}
+
+// Ways of matching the title search. An exact search matches whole words, while a fuzzy
+// one matches titles that are spelt similarly, so "godfater" still finds "The Godfather".
+const (
+	MatchExact = "exact"
+	MatchFuzzy = "fuzzy"
+)
+
+// Matches lists every title match mode.
+var Matches = []string{MatchExact, MatchFuzzy}
+
+// DefaultSimilarity is the trigram similarity a title needs to match a fuzzy search if
+// the client doesn't ask for another. It's the pg_trgm default.
+const DefaultSimilarity = 0.3
+
+// MovieFilterFields is the safelist of movie columns a filter expression can refer to.
+// Each name is also the column's name, and ends up in the SQL as is.
+var MovieFilterFields = filter.Fields{
+	"id":             filter.Integer,
+	"title":          filter.Text,
+	"year":           filter.Integer,
+	"runtime":        filter.Integer,
+	"genres":         filter.TextList,
+	"average_rating": filter.Float,
+	"rating_count":   filter.Integer,
+	"created_at":     filter.Timestamp,
+}
+
+// filterOperators maps the comparison operators in a filter expression to SQL. "~" and
+// "HAS" are handled separately.
+var filterOperators = map[string]string{
+	"=":  "=",
+	"!=": "<>",
+	"<":  "<",
+	"<=": "<=",
+	">":  ">",
+	">=": ">=",
+}
+
+// MovieFilter narrows down the movies returned by GetAll and Export. Zero values mean
+// "don't filter on this". Deleted movies are always left out, unless Trashed is set, in
+// which case only they are included.
+type MovieFilter struct {
+	Title         string
+	Match         string
+	Similarity    float64
+	Genres        []string
+	GenreMatch    string
+	YearMin       int
+	YearMax       int
+	RuntimeMin    int
+	RuntimeMax    int
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Expression    filter.Node
+	Trashed       bool
+}
+
+func ValidateMovieFilter(v *validator.Validator, f MovieFilter) {
+	v.CheckCode(validator.In(f.Match, Matches...), "match", validator.CodeNotPermitted, "invalid match", validator.Params{"allowed": Matches})
+	v.CheckCode(f.Similarity >= 0.1 && f.Similarity <= 1, "similarity", validator.CodeOutOfRange, "must be between 0.1 and 1", validator.Params{"min": 0.1, "max": 1})
+
+	v.CheckCode(validator.In(f.GenreMatch, GenreMatches...), "genre_match", validator.CodeNotPermitted, "invalid genre match", validator.Params{"allowed": GenreMatches})
+
+	v.CheckCode(f.YearMin == 0 || f.YearMin >= 1888, "year_min", validator.CodeMinValue, "must be greater than 1888", validator.Params{"min": 1888})
+	v.CheckCode(f.YearMax == 0 || f.YearMax >= 1888, "year_max", validator.CodeMinValue, "must be greater than 1888", validator.Params{"min": 1888})
+	v.CheckCode(f.YearMin == 0 || f.YearMax == 0 || f.YearMax >= f.YearMin, "year_max", validator.CodeMinValue, "must not be less than year_min", validator.Params{"min": f.YearMin})
+
+	v.CheckCode(f.RuntimeMin >= 0, "runtime_min", validator.CodeMinValue, "must not be negative", validator.Params{"min": 0})
+	v.CheckCode(f.RuntimeMax >= 0, "runtime_max", validator.CodeMinValue, "must not be negative", validator.Params{"min": 0})
+	v.CheckCode(f.RuntimeMin == 0 || f.RuntimeMax == 0 || f.RuntimeMax >= f.RuntimeMin, "runtime_max", validator.CodeMinValue, "must not be less than runtime_min", validator.Params{"min": f.RuntimeMin})
+
+	ValidateCreatedRange(v, f.CreatedAfter, f.CreatedBefore)
+}
+
+// ValidateCreatedRange checks the created_after and created_before parameters describe a
+// range. Either can be zero for an open-ended range.
+func ValidateCreatedRange(v *validator.Validator, after, before time.Time) {
+	if !after.IsZero() && !before.IsZero() {
+		v.CheckCode(before.After(after), "created_before", validator.CodeNotAfter, "must be after created_after", validator.Params{"after": after.Format(time.RFC3339)})
+	}
+}
+
+// The title is highlighted with control characters rather than tags, so the rest of it
+// can be HTML-escaped before the matches are wrapped in <mark> tags by markHighlight.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+// headlineOptions is interpolated into the query, so it mustn't contain a quote.
+const headlineOptions = "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", HighlightAll=true"
+
+// markHighlight turns a headline from ts_headline into HTML: the title is escaped and the
+// matches are wrapped in <mark> tags.
+func markHighlight(headline string) string {
+	var b strings.Builder
+
+	for {
+		before, rest, found := strings.Cut(headline, highlightStart)
+		b.WriteString(html.EscapeString(before))
+		if !found {
+			break
+		}
+
+		match, after, _ := strings.Cut(rest, highlightStop)
+		b.WriteString("<mark>" + html.EscapeString(match) + "</mark>")
+		headline = after
+	}
+
+	return b.String()
+}
+
+// titleSearch holds the SQL expressions that depend on how the title was searched.
+type titleSearch struct {
+	rank     string // how well a movie matches, higher is better
+	headline string // the title with the matches marked, or "" if there's none
+}
+
+// fuzzy reports whether the filter searches titles by trigram similarity.
+func (f MovieFilter) fuzzy() bool {
+	return f.Title != "" && f.Match == MatchFuzzy
+}
+
+// withFilter runs fn with a model ready for queries built from the filter's where()
+// clause. A fuzzy search filters with pg_trgm's % operator, whose threshold is the
+// pg_trgm.similarity_threshold setting rather than an argument, so fn is run in a
+// snapshot with the setting made for just that transaction.
+func (m MovieModel) withFilter(f MovieFilter, fn func(m MovieModel) error) error {
+	if !f.fuzzy() {
+		return fn(m)
+	}
+
+	return m.withSnapshot(func(m MovieModel) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		threshold := strconv.FormatFloat(f.Similarity, 'f', -1, 64)
+
+		_, err := m.conn().ExecContext(ctx, "SELECT set_config('pg_trgm.similarity_threshold', $1, true)", threshold)
+		if err != nil {
+			return err
+		}
+
+		return fn(m)
+	})
+}
+
+// where builds the WHERE clause for the filter. Every value is passed as a placeholder,
+// numbered from $1, and returned in args in order, so callers can add their own
+// placeholders after them. If the title is being searched, search is set, for ranking
+// and highlighting.
+func (f MovieFilter) where() (clause string, args []interface{}, search *titleSearch) {
+	conditions := []string{"deleted_at IS NULL"}
+	if f.Trashed {
+		conditions[0] = "deleted_at IS NOT NULL"
+	}
+
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if f.Title != "" {
+		switch f.Match {
+		case MatchFuzzy:
+			// The % operator is what lets the trigram index serve the search. It takes
+			// its threshold from a setting, which withFilter sets to f.Similarity; the
+			// explicit check keeps the results right should it not have been.
+			title := arg(f.Title)
+			similarity := fmt.Sprintf("similarity(title, %s)", title)
+			conditions = append(conditions, fmt.Sprintf("title %% %s", title), fmt.Sprintf("%s >= %s", similarity, arg(f.Similarity)))
+			search = &titleSearch{rank: similarity}
+		default:
+			// The exact search takes web search syntax: "quoted phrases", OR, and -word
+			// to exclude a word.
+			query := fmt.Sprintf("websearch_to_tsquery('simple', %s)", arg(f.Title))
+			conditions = append(conditions, fmt.Sprintf("search_vector @@ %s", query))
+			search = &titleSearch{
+				rank:     fmt.Sprintf("ts_rank(search_vector, %s)", query),
+				headline: fmt.Sprintf("ts_headline('simple', title, %s, '%s')", query, headlineOptions),
+			}
+		}
+	}
+
+	if len(f.Genres) > 0 {
+		switch f.GenreMatch {
+		case GenreMatchAny:
+			conditions = append(conditions, fmt.Sprintf("genres && %s", arg(pq.Array(f.Genres))))
+		case GenreMatchNone:
+			conditions = append(conditions, fmt.Sprintf("NOT genres && %s", arg(pq.Array(f.Genres))))
+		default:
+			conditions = append(conditions, fmt.Sprintf("genres @> %s", arg(pq.Array(f.Genres))))
+		}
+	}
+
+	if f.YearMin != 0 {
+		conditions = append(conditions, fmt.Sprintf("year >= %s", arg(f.YearMin)))
+	}
+	if f.YearMax != 0 {
+		conditions = append(conditions, fmt.Sprintf("year <= %s", arg(f.YearMax)))
+	}
+
+	if f.RuntimeMin != 0 {
+		conditions = append(conditions, fmt.Sprintf("runtime >= %s", arg(f.RuntimeMin)))
+	}
+	if f.RuntimeMax != 0 {
+		conditions = append(conditions, fmt.Sprintf("runtime <= %s", arg(f.RuntimeMax)))
+	}
+
+	if !f.CreatedAfter.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("created_at > %s", arg(f.CreatedAfter)))
+	}
+	if !f.CreatedBefore.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("created_at < %s", arg(f.CreatedBefore)))
+	}
+
+	if f.Expression != nil {
+		conditions = append(conditions, compileExpression(f.Expression, arg))
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args, search
+}
+
+// compileExpression turns a parsed filter expression into SQL, passing every value
+// through arg as a placeholder. Fields and operators are checked against the safelists
+// again because they end up in the SQL itself, so this is our last line of defence
+// against injection.
+func compileExpression(node filter.Node, arg func(value interface{}) string) string {
+	switch n := node.(type) {
+	case *filter.And:
+		return "(" + compileExpression(n.Left, arg) + " AND " + compileExpression(n.Right, arg) + ")"
+	case *filter.Or:
+		return "(" + compileExpression(n.Left, arg) + " OR " + compileExpression(n.Right, arg) + ")"
+	case *filter.Not:
+		return "NOT (" + compileExpression(n.Expr, arg) + ")"
+	case *filter.Comparison:
+		if _, ok := MovieFilterFields[n.Field]; !ok {
+			panic("unsafe filter field: " + n.Field)
+		}
+
+		switch n.Operator {
+		case "HAS":
+			return fmt.Sprintf("%s @> %s", n.Field, arg(pq.Array([]string{n.Value.(string)})))
+		case "~":
+			return fmt.Sprintf("%s ILIKE %s", n.Field, arg("%"+escapeLike(n.Value.(string))+"%"))
+		}
+
+		op, ok := filterOperators[n.Operator]
+		if !ok {
+			panic("unsafe filter operator: " + n.Operator)
+		}
+
+		return fmt.Sprintf("%s %s %s", n.Field, op, arg(n.Value))
+	}
+
+	panic(fmt.Sprintf("unexpected filter node %T", node))
+}
+
+// escapeLike escapes the LIKE wildcards in s, so it's matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
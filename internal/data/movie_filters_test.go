@@ -0,0 +1,84 @@
+package data
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/goddhi/zeliz-movie/internal/filter"
+	"github.com/lib/pq"
+)
+
+func TestCompileExpression(t *testing.T) {
+	tests := []struct {
+		input string
+		sql   string
+		args  []interface{}
+	}{
+		{
+			`year >= 1990`,
+			`year >= $1`,
+			[]interface{}{int64(1990)},
+		},
+		{
+			`average_rating != 5.5`,
+			`average_rating <> $1`,
+			[]interface{}{5.5},
+		},
+		{
+			`year >= 1990 AND (genres HAS "drama" OR runtime < 90)`,
+			`(year >= $1 AND (genres @> $2 OR runtime < $3))`,
+			[]interface{}{int64(1990), pq.Array([]string{"drama"}), int64(90)},
+		},
+		{
+			`NOT title = "x" OR id = 1`,
+			`(NOT (title = $1) OR id = $2)`,
+			[]interface{}{"x", int64(1)},
+		},
+		{
+			`created_at > "2024-01-02"`,
+			`created_at > $1`,
+			[]interface{}{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		},
+
+		// Values never reach the SQL itself, and LIKE wildcards are matched literally.
+		{
+			`title ~ "100%_\\'; DROP TABLE movies; --"`,
+			`title ILIKE $1`,
+			[]interface{}{`%100\%\_\\'; DROP TABLE movies; --%`},
+		},
+	}
+
+	for _, tt := range tests {
+		node, err := filter.Parse(tt.input, MovieFilterFields)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", tt.input, err)
+			continue
+		}
+
+		var args []interface{}
+		arg := func(value interface{}) string {
+			args = append(args, value)
+			return "$" + strconv.Itoa(len(args))
+		}
+
+		if got := compileExpression(node, arg); got != tt.sql {
+			t.Errorf("compileExpression(%q) = %s; want %s", tt.input, got, tt.sql)
+		}
+
+		if !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("compileExpression(%q) args = %#v; want %#v", tt.input, args, tt.args)
+		}
+	}
+}
+
+func TestCompileExpressionRejectsUnsafeFields(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("compileExpression didn't panic on a field outside the safelist")
+		}
+	}()
+
+	compileExpression(&filter.Comparison{Field: "1; DROP TABLE movies", Operator: "=", Value: int64(1)}, func(interface{}) string { return "$1" })
+}
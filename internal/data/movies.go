@@ -1,234 +1,645 @@
-package data
-
-import (
-	"context"
-	"database/sql"
-	"errors"
-	"time"
-
-	"github.com/goddhi/zeliz-movie/internal/validator"
-	"github.com/lib/pq"
-)
-
-//MovieModel struct type which wraps a sql.DB connection pool.
-type MovieModel struct {
-	DB *sql.DB
-}
-
-
-type Movie struct {
-	ID			int64  `json:"id"`   // Unique integer ID for the movie
-	CreateAt	time.Time `json:"-"` // Timestamp for when the movie is added to our database
-	Title		string  `json:"title"` // Movie title
-	Year 		int32  `json:"year,omitempty"`// Movie release year
-	Runtime		Runtime `json:"runtime,omitempty"`// Movie runtime (in minutes)
-	Genres		[]string `json:"genres,omitempty"`//Slice of genres for the movied (romance, comedy, etc)
-	Version		int32 `json:"version"`// time the movie information is updated
-
-}
-
-
-func (m MovieModel) Insert(movie *Movie) error {
-
-	query := `
-				INSERT INTO movies (title, year, runtime, genres)
-				VALUES ($1, $2, $3, $4)
-				RETURNING id, created_at, version`
-
-			// an args slice containing the values for the placeholder parameters from
-			// the movie struct.
-	args := []interface{}{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
-
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	// Use the QueryRow() method to execute the SQL query on our connection pool,
-	// passing in the args slice as a variadic parameter and scanning the system-
-	// generated id, created_at and version values into the movie struct.
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreateAt, &movie.Version)
-}
-
-
-func (m *MovieModel) Get(id int64) (*Movie, error) {
-
-	if id < 1 {
-		return nil, ErrRecordNotFound
-	}
-
-	query := `
-				SELECT id, created_at, title, year, runtime, genres, version
-				FROM movies
-				WHERE id = $1`
-
-	// this holds the data returned by the query
-	var movie Movie
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-
-	defer cancel()
-
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
-		&[]byte{},
-		&movie.ID,
-		&movie.CreateAt,
-		&movie.Title,
-		&movie.Year,
-		&movie.Runtime,
-		pq.Array(&movie.Genres),
-		&movie.Version,
-	)
-
-	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return nil, ErrRecordNotFound
-		default:
-			return nil, err
-		}
-	}
-
-	return &movie, nil
-}
-
-func (m *MovieModel) Update(movie *Movie) error {
-	query := `
-			UPDATE movies
-			SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-			WHERE id = $5 AND version = $6
-			RETURNING version`
-
-
-	args := []interface{}{
-		movie.Title,
-		movie.Year,
-		movie.Runtime,
-		pq.Array(movie.Genres),
-		movie.ID,
-		movie.Version,
-
-}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-// Execute the SQL query. If no matching row could be found, we know the movie
-// version has changed (or the record has been deleted) and we return our custom
-// ErrEditConflict error.
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
-	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return err
-		default:
-			return err
-		}
-	}
-	return nil
-}
-
-func (m *MovieModel) Delete(id int64) error {
-	if id < 1 {
-		return ErrRecordNotFound
-	}
-
-	query := `
-		DELETE FROM movies
-		WHERE id = $1
-	`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	
-
-	result, err := m.DB.ExecContext(ctx, query, id)
-	if err != nil {
-		return err
-	}
-
-
-	// Call the RowsAffected() method on the sql.Result object to get the number of rows
-	// affected by the query.
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
-	}
-	return nil
-}
-
-
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, error) {
-	// CSQL query to retrieve all movie records.
-	query := `
-				SELECT id, created_at, title, year, runtime, genres, version
-				FROM movies
-				WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-				AND (genres @> $2 OR $2 = '{}')
-				ORDER BY id`
-
-
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	// Pass the title and genres as the placeholder parameter values.
-	rows, err := m.DB.QueryContext(ctx, query, title, pq.Array(genres))
-	if err != nil {
-		return nil, err
-	}
-
-	// Importantly, defer a call to rows.Close() to ensure that the resultset is closed
-// before GetAll() returns.
-	defer rows.Close()
-
-	movies := []*Movie{}
-
-	for rows.Next() {
-		// Initialize an empty Movie struct to hold the data for an individual movie.
-		var movie Movie
-
-		err := rows.Scan(
-			&movie.ID,
-			&movie.CreateAt,
-			&movie.Title,
-			&movie.Year,
-			&movie.Runtime,
-			pq.Array(&movie.Genres),
-			&movie.Version,
-		)
-
-		if err != nil {
-			return nil, err
-		}
-
-		// Add the Movie struct to the slice.
-		movies = append(movies, &movie)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return movies, nil
-}
-
-
-func ValidateMovie(v *validator.Validator, movie *Movie) {
-	v.Check(movie.Title != "", "title", "must be provided")
-	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
-	
-	v.Check(movie.Year != 0, "year", "must be provided")
-	v.Check(movie.Year >= 1888, "year", "must be greater than 1888")
-	v.Check(movie.Year <= int32(time.Now().Year()), "year", "must not be in the future")
-	
-	v.Check(movie.Runtime != 0, "runtime", "must be provided")
-	v.Check(movie.Runtime > 0, "runtime", "must be a positive integer")
-	
-	v.Check(movie.Genres != nil, "genres", "must be provided")
-	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre")
-	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
-	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
-}
-
-
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/goddhi/zeliz-movie/internal/validator"
+	"github.com/lib/pq"
+)
+
+//MovieModel struct type which wraps a sql.DB connection pool.
+type MovieModel struct {
+	DB *sql.DB
+
+	tx *sql.Tx // set when the model belongs to a MovieTx
+
+	fields []string // set by Select; nil reads every field
+}
+
+// dbtx is the part of the API shared by *sql.DB and *sql.Tx that our queries use.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// conn returns the transaction the model is bound to, or the connection pool.
+func (m MovieModel) conn() dbtx {
+	if m.tx != nil {
+		return m.tx
+	}
+	return m.DB
+}
+
+// MovieTx is a MovieModel whose queries all run inside one database transaction. Call
+// Commit() or Rollback() once done with it.
+type MovieTx struct {
+	MovieModel
+}
+
+// Begin starts a transaction and returns a MovieTx bound to it.
+func (m MovieModel) Begin() (*MovieTx, error) {
+	// The transaction lives for as long as the caller needs it, so it isn't given a
+	// timeout here; each query still gets its own.
+	tx, err := m.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MovieTx{MovieModel{DB: m.DB, tx: tx, fields: m.fields}}, nil
+}
+
+func (t *MovieTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *MovieTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// Savepoint marks a point that RollbackToSavepoint can return to. Postgres refuses any
+// further statements in a transaction after one fails, so this lets a caller undo a
+// failed step and carry on. Savepoints nest; each one should be released or rolled back.
+func (t *MovieTx) Savepoint() error {
+	_, err := t.tx.Exec("SAVEPOINT movie_tx")
+	return err
+}
+
+// ReleaseSavepoint keeps the work done since the latest savepoint.
+func (t *MovieTx) ReleaseSavepoint() error {
+	_, err := t.tx.Exec("RELEASE SAVEPOINT movie_tx")
+	return err
+}
+
+// RollbackToSavepoint undoes the work done since the latest savepoint and removes it.
+func (t *MovieTx) RollbackToSavepoint() error {
+	_, err := t.tx.Exec("ROLLBACK TO SAVEPOINT movie_tx")
+	if err != nil {
+		return err
+	}
+	return t.ReleaseSavepoint()
+}
+
+// withTx runs fn with a model bound to a transaction, committing if fn succeeds. If m is
+// already bound to one, fn just runs as part of it.
+func (m MovieModel) withTx(fn func(m MovieModel) error) error {
+	if m.tx != nil {
+		return fn(m)
+	}
+
+	tx, err := m.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx.MovieModel); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+
+type Movie struct {
+	ID			int64  `json:"id"`   // Unique integer ID for the movie
+	CreateAt	time.Time `json:"-"` // Timestamp for when the movie is added to our database
+	Title		string  `json:"title"` // Movie title
+	Year 		int32  `json:"year,omitempty"`// Movie release year
+	Runtime		Runtime `json:"runtime,omitempty"`// Movie runtime (in minutes)
+	Genres		[]string `json:"genres,omitempty"`//Slice of genres for the movied (romance, comedy, etc)
+	ExternalIDs	map[string]string `json:"external_ids,omitempty"` // Identifiers in other catalogues, keyed by source (imdb, tmdb, wikidata)
+	Credits		[]*Credit `json:"credits,omitempty"` // Cast and crew, only loaded when asked for
+	AverageRating	float64 `json:"average_rating"` // Mean of the users' scores, 0 if nobody has rated it yet
+	RatingCount	int32 `json:"rating_count"` // Number of users who have rated the movie
+	RatingSummary	*RatingSummary `json:"rating_summary,omitempty"` // Breakdown of the scores, only loaded when asked for
+	Highlight	string `json:"highlight,omitempty"` // HTML-escaped title with the search matches wrapped in <mark> tags, only set when searching
+	DeletedAt	*time.Time `json:"deleted_at,omitempty"` // When the movie was moved to the trash, only set for movies in it
+	Version		int32 `json:"version"`// time the movie information is updated
+
+	runtimeFormat RuntimeFormat // how Runtime is written out by MarshalJSON
+	fields        []string      // the fields MarshalJSON writes out, if not all of them
+}
+
+// SetRuntimeFormat chooses how the movie's runtime is rendered in JSON responses.
+func (movie *Movie) SetRuntimeFormat(f RuntimeFormat) {
+	movie.runtimeFormat = f
+}
+
+// MarshalJSON writes the movie as usual, except that the runtime is rendered in the
+// format chosen with SetRuntimeFormat ("N mins" if none was set), and only the fields
+// it was read with are written if it came from a model limited by Select.
+func (movie Movie) MarshalJSON() ([]byte, error) {
+	// movieJSON has the same fields but none of the methods, so it doesn't recurse.
+	type movieJSON Movie
+
+	var runtime interface{}
+	if movie.Runtime != 0 {
+		runtime = movie.Runtime.Format(movie.runtimeFormat)
+	}
+
+	js, err := json.Marshal(struct {
+		movieJSON
+		Runtime interface{} `json:"runtime,omitempty"`
+	}{movieJSON(movie), runtime})
+	if err != nil || movie.fields == nil {
+		return js, err
+	}
+
+	return movie.pick(js)
+}
+
+
+// Insert adds a new movie. If movie.ExternalIDs is set they're stored along with it, in
+// the same transaction.
+func (m MovieModel) Insert(movie *Movie) error {
+
+	if movie.ExternalIDs != nil && m.tx == nil {
+		return m.withTx(func(m MovieModel) error { return m.Insert(movie) })
+	}
+
+	query := `
+				INSERT INTO movies (title, year, runtime, genres)
+				VALUES ($1, $2, $3, $4)
+				RETURNING id, created_at, version`
+
+			// an args slice containing the values for the placeholder parameters from
+			// the movie struct.
+	args := []interface{}{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
+
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	// Use the QueryRow() method to execute the SQL query on our connection pool,
+	// passing in the args slice as a variadic parameter and scanning the system-
+	// generated id, created_at and version values into the movie struct.
+	err := m.conn().QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreateAt, &movie.Version)
+	if err != nil {
+		return err
+	}
+
+	return m.syncExternalIDs(movie)
+}
+
+
+// InsertMany bulk loads movies with a single COPY inside a transaction, so either all of
+// them are inserted or none are. Unlike Insert it doesn't read back the generated ids.
+func (m MovieModel) InsertMany(movies []*Movie) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("movies", "title", "year", "runtime", "genres"))
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		_, err = stmt.ExecContext(ctx, movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres))
+		if err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+
+	// An Exec with no arguments flushes the buffered rows to the server.
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return err
+	}
+
+	if err = stmt.Close(); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+
+func (m *MovieModel) Get(id int64) (*Movie, error) {
+
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	// Only the selected columns are read; see Select.
+	columns, dests := m.columns()
+
+	query := `
+				SELECT ` + columns + `
+				FROM movies
+				WHERE id = $1 AND deleted_at IS NULL`
+
+	// this holds the data returned by the query
+	var movie Movie
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+
+	defer cancel()
+
+	err := m.conn().QueryRowContext(ctx, query, id).Scan(dests(&movie)...)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if m.selects("external_ids") {
+		err = m.LoadExternalIDs([]*Movie{&movie})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &movie, nil
+}
+
+// Update saves changes to a movie, provided its version hasn't changed since it was
+// read. If movie.ExternalIDs is non-nil the stored identifiers are replaced with it.
+func (m *MovieModel) Update(movie *Movie) error {
+
+	if movie.ExternalIDs != nil && m.tx == nil {
+		return m.withTx(func(m MovieModel) error { return m.Update(movie) })
+	}
+
+	query := `
+			UPDATE movies
+			SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
+			WHERE id = $5 AND version = $6 AND deleted_at IS NULL
+			RETURNING version`
+
+
+	args := []interface{}{
+		movie.Title,
+		movie.Year,
+		movie.Runtime,
+		pq.Array(movie.Genres),
+		movie.ID,
+		movie.Version,
+
+}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+// Execute the SQL query. If no matching row could be found, we know the movie
+// version has changed (or the record has been deleted) and we return our custom
+// ErrEditConflict error.
+	err := m.conn().QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return m.syncExternalIDs(movie)
+}
+
+// Delete moves a movie to the trash. It's left out of everything else from then on, but
+// can be brought back with Restore until Purge removes it for good.
+func (m *MovieModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE movies
+		SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	
+
+	result, err := m.conn().ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+
+	// Call the RowsAffected() method on the sql.Result object to get the number of rows
+	// affected by the query.
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+
+// Restore takes a movie back out of the trash, returning ErrRecordNotFound if it isn't
+// in it.
+func (m MovieModel) Restore(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE movies
+		SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.conn().ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Purge permanently deletes the movies that were moved to the trash before the given
+// time, along with everything that belongs to them, and returns how many there were.
+func (m MovieModel) Purge(before time.Time) (int64, error) {
+	query := `
+		DELETE FROM movies
+		WHERE deleted_at < $1`
+
+	// There may be a lot of them, so this is given longer than the usual queries.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := m.conn().ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// GetAll returns a page of the movies matching the filter.
+func (m MovieModel) GetAll(filter MovieFilter, filters Filters) ([]*Movie, error) {
+	var movies []*Movie
+
+	err := m.withFilter(filter, func(m MovieModel) error {
+		var err error
+		movies, err = m.getAll(filter, filters)
+		return err
+	})
+
+	return movies, err
+}
+
+func (m MovieModel) getAll(filter MovieFilter, filters Filters) ([]*Movie, error) {
+	// The WHERE clause only has conditions for the filters that were set, and takes the
+	// first placeholders; the page comes after them.
+	where, args, search := filter.where()
+
+	// The sort column and direction can't be placeholders, so they're interpolated;
+	// sortColumn() only ever returns a value from the SortStatelist.
+	orderBy := fmt.Sprintf("%s %s", filters.sortColumn(), filters.sortDirection())
+
+	// When searching on the title, the matches are highlighted and can be ranked by how
+	// well they match, best first.
+	highlight := "''"
+	if search != nil {
+		if search.headline != "" && m.selects("highlight") {
+			highlight = search.headline
+		}
+
+		if filters.sortColumn() == "relevance" {
+			orderBy = search.rank + " DESC"
+		}
+	}
+
+	// Only the selected columns are read; see Select.
+	columns, dests := m.columns()
+
+	// CSQL query to retrieve all movie records.
+	query := `
+				SELECT %s, %s
+				FROM movies
+				%s
+				ORDER BY %s, id ASC
+				LIMIT $%d OFFSET $%d`
+
+	query = fmt.Sprintf(query, columns, highlight, where, orderBy, len(args)+1, len(args)+2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// Pass the filter values and page as the placeholder parameter values.
+	args = append(args, filters.limit(), filters.offset())
+
+	rows, err := m.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Importantly, defer a call to rows.Close() to ensure that the resultset is closed
+// before GetAll() returns.
+	defer rows.Close()
+
+	movies := []*Movie{}
+
+	for rows.Next() {
+		// Initialize an empty Movie struct to hold the data for an individual movie.
+		var movie Movie
+
+		err := rows.Scan(append(dests(&movie), &movie.Highlight)...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if movie.Highlight != "" {
+			movie.Highlight = markHighlight(movie.Highlight)
+		}
+
+		// Add the Movie struct to the slice.
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Fetch the external ids for the whole page in one go.
+	if m.selects("external_ids") {
+		err = m.LoadExternalIDs(movies)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return movies, nil
+}
+
+
+// MovieTitle is the little of a movie needed to offer it as a search suggestion.
+type MovieTitle struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Year  int32  `json:"year"`
+}
+
+// Titles returns the id, title and year of every movie, for the suggestion cache. Like
+// Features, it reads the whole catalogue, so it's given longer than the usual queries.
+func (m MovieModel) Titles() ([]*MovieTitle, error) {
+	query := `
+				SELECT id, title, year
+				FROM movies
+				WHERE deleted_at IS NULL
+				ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rows, err := m.conn().QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	titles := []*MovieTitle{}
+
+	for rows.Next() {
+		var title MovieTitle
+
+		err := rows.Scan(&title.ID, &title.Title, &title.Year)
+		if err != nil {
+			return nil, err
+		}
+
+		titles = append(titles, &title)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return titles, nil
+}
+
+// SuggestTitle returns the title most similar to title, for a "did you mean" hint when a
+// search finds nothing, or "" if no title is similar enough.
+func (m MovieModel) SuggestTitle(title string) (string, error) {
+	query := `
+				SELECT title
+				FROM movies
+				WHERE title % $1 AND deleted_at IS NULL
+				ORDER BY title <-> $1
+				LIMIT 1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var suggestion string
+
+	err := m.conn().QueryRowContext(ctx, query, title).Scan(&suggestion)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", nil
+		default:
+			return "", err
+		}
+	}
+
+	return suggestion, nil
+}
+
+// Export streams every movie matching the same filter as GetAll to fn, one row at a time
+// straight off the database cursor, so the whole catalogue never has to be held in
+// memory. It stops at the first error returned by fn. The ctx should be the request's,
+// so the query is cancelled if the client goes away.
+func (m MovieModel) Export(ctx context.Context, filter MovieFilter, fn func(movie *Movie) error) error {
+	return m.withFilter(filter, func(m MovieModel) error {
+		return m.export(ctx, filter, fn)
+	})
+}
+
+func (m MovieModel) export(ctx context.Context, filter MovieFilter, fn func(movie *Movie) error) error {
+	where, args, _ := filter.where()
+
+	query := `
+				SELECT id, created_at, title, year, runtime, genres, average_rating, rating_count, version
+				FROM movies
+				` + where + `
+				ORDER BY id`
+
+	rows, err := m.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(
+			&movie.ID,
+			&movie.CreateAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&movie.Version,
+		)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(&movie); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+
+func ValidateMovie(v *validator.Validator, movie *Movie) {
+	v.CheckCode(movie.Title != "", "title", validator.CodeRequired, "must be provided", nil)
+	v.CheckCode(utf8.RuneCountInString(movie.Title) <= 500, "title", validator.CodeMaxLength, "must not be more than 500 characters long", validator.Params{"max": 500})
+	
+	v.CheckCode(movie.Year != 0, "year", validator.CodeRequired, "must be provided", nil)
+	v.CheckCode(movie.Year >= 1888, "year", validator.CodeMinValue, "must be greater than 1888", validator.Params{"min": 1888})
+	v.CheckCode(movie.Year <= int32(time.Now().Year()), "year", validator.CodeInFuture, "must not be in the future", validator.Params{"max": time.Now().Year()})
+	
+	v.CheckCode(movie.Runtime != 0, "runtime", validator.CodeRequired, "must be provided", nil)
+	v.CheckCode(movie.Runtime > 0, "runtime", validator.CodeMinValue, "must be a positive integer", validator.Params{"min": 1})
+	
+	v.CheckCode(movie.Genres != nil, "genres", validator.CodeRequired, "must be provided", nil)
+	v.CheckCode(len(movie.Genres) >= 1, "genres", validator.CodeMinItems, "must contain at least 1 genre", validator.Params{"min": 1})
+	v.CheckCode(len(movie.Genres) <= 5, "genres", validator.CodeMaxItems, "must not contain more than 5 genres", validator.Params{"max": 5})
+	v.CheckCode(validator.Unique(movie.Genres), "genres", validator.CodeDuplicate, "must not contain duplicate values", nil)
+
+	ValidateExternalIDs(v, movie.ExternalIDs)
+}
+
+
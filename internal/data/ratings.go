@@ -0,0 +1,246 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/goddhi/zeliz-movie/internal/validator"
+	"github.com/lib/pq"
+)
+
+// ErrDuplicateRating is returned when a user rates a movie they've already rated.
+var ErrDuplicateRating = errors.New("duplicate rating")
+
+// Rating is one user's score for a movie, from 1 to 10.
+type Rating struct {
+	MovieID   int64     `json:"movie_id"`
+	UserID    int64     `json:"user_id"`
+	Score     int16     `json:"score"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RatingModel struct type which wraps a sql.DB connection pool.
+type RatingModel struct {
+	DB *sql.DB
+}
+
+func ValidateRating(v *validator.Validator, rating *Rating) {
+	v.CheckCode(rating.Score >= 1 && rating.Score <= 10, "score", validator.CodeOutOfRange, "must be between 1 and 10", validator.Params{"min": 1, "max": 10})
+}
+
+func (m RatingModel) Get(userID, movieID int64) (*Rating, error) {
+	query := `
+		SELECT movie_id, user_id, score, created_at, updated_at
+		FROM ratings
+		WHERE user_id = $1 AND movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var rating Rating
+
+	err := m.DB.QueryRowContext(ctx, query, userID, movieID).Scan(&rating.MovieID, &rating.UserID, &rating.Score, &rating.CreatedAt, &rating.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &rating, nil
+}
+
+// Insert adds a new rating, returning ErrDuplicateRating if the user has already rated
+// the movie, and updates the movie's aggregates.
+func (m RatingModel) Insert(rating *Rating) error {
+	query := `
+		INSERT INTO ratings (user_id, movie_id, score)
+		VALUES ($1, $2, $3)
+		RETURNING created_at, updated_at`
+
+	return m.write(rating.MovieID, func(ctx context.Context, tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, rating.UserID, rating.MovieID, rating.Score).Scan(&rating.CreatedAt, &rating.UpdatedAt)
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+				return ErrDuplicateRating
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// Upsert adds the rating or replaces the user's existing score for the movie, and
+// updates the movie's aggregates. It reports whether the rating was newly created.
+func (m RatingModel) Upsert(rating *Rating) (bool, error) {
+	// xmax is only zero for a row that was inserted rather than updated.
+	query := `
+		INSERT INTO ratings (user_id, movie_id, score)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, movie_id) DO UPDATE SET score = EXCLUDED.score, updated_at = NOW()
+		RETURNING created_at, updated_at, xmax = 0`
+
+	var created bool
+
+	err := m.write(rating.MovieID, func(ctx context.Context, tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, query, rating.UserID, rating.MovieID, rating.Score).Scan(&rating.CreatedAt, &rating.UpdatedAt, &created)
+	})
+
+	return created, err
+}
+
+// Delete removes the user's rating for the movie and updates the movie's aggregates.
+func (m RatingModel) Delete(userID, movieID int64) error {
+	query := `
+		DELETE FROM ratings
+		WHERE user_id = $1 AND movie_id = $2`
+
+	return m.write(movieID, func(ctx context.Context, tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query, userID, movieID)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+
+		return nil
+	})
+}
+
+// write runs fn and then recalculates the movie's average_rating and rating_count, all in
+// one transaction. It returns ErrRecordNotFound if the movie doesn't exist. The movie's
+// version isn't bumped: the aggregates aren't something a client can edit, so they
+// shouldn't cause edit conflicts.
+func (m RatingModel) write(movieID int64, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the movie first so concurrent writes for the same movie queue up here, and the
+	// recalculation below always sees every committed rating.
+	var id int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM movies WHERE id = $1 AND deleted_at IS NULL FOR NO KEY UPDATE`, movieID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	err = fn(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE movies
+		SET average_rating = COALESCE(r.average, 0), rating_count = r.count
+		FROM (SELECT round(avg(score), 2) AS average, count(*) AS count FROM ratings WHERE movie_id = $1) r
+		WHERE movies.id = $1`
+
+	_, err = tx.ExecContext(ctx, query, movieID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RatingSummary describes how a movie has been rated: the average and number of scores,
+// and how many users gave each score.
+type RatingSummary struct {
+	Average      float64       `json:"average"`
+	Count        int64         `json:"count"`
+	Distribution []*ScoreCount `json:"distribution"`
+}
+
+// ScoreCount is the number of users who gave a movie a particular score.
+type ScoreCount struct {
+	Score int16 `json:"score"`
+	Count int64 `json:"count"`
+}
+
+// SummariesForMovies returns a summary of the ratings of each of the given movies, keyed
+// by movie id, using a single query. Every movie gets a summary, with every score from 1
+// to 10 listed in its distribution, even if nobody has rated it.
+func (m RatingModel) SummariesForMovies(movieIDs []int64) (map[int64]*RatingSummary, error) {
+	summaries := make(map[int64]*RatingSummary, len(movieIDs))
+
+	for _, id := range movieIDs {
+		summary := &RatingSummary{Distribution: make([]*ScoreCount, 10)}
+		for i := range summary.Distribution {
+			summary.Distribution[i] = &ScoreCount{Score: int16(i + 1)}
+		}
+		summaries[id] = summary
+	}
+
+	if len(movieIDs) == 0 {
+		return summaries, nil
+	}
+
+	query := `
+		SELECT movie_id, score, count(*)
+		FROM ratings
+		WHERE movie_id = ANY($1)
+		GROUP BY movie_id, score`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			movieID int64
+			score   int16
+			count   int64
+		)
+
+		if err := rows.Scan(&movieID, &score, &count); err != nil {
+			return nil, err
+		}
+
+		summary := summaries[movieID]
+		summary.Distribution[score-1].Count = count
+		summary.Count += count
+		summary.Average += float64(score) * float64(count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Worked out from the same counts as the distribution, so the two always agree.
+	for _, summary := range summaries {
+		if summary.Count > 0 {
+			summary.Average = math.Round(summary.Average/float64(summary.Count)*100) / 100
+		}
+	}
+
+	return summaries, nil
+}
@@ -0,0 +1,290 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/goddhi/zeliz-movie/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Review moderation states. A new or edited review is pending until a moderator approves
+// or rejects it, and only approved reviews are shown publicly.
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// ReviewStatuses lists every moderation state.
+var ReviewStatuses = []string{ReviewPending, ReviewApproved, ReviewRejected}
+
+// reviewTransitions lists the states a moderator can move a review to from each state.
+// Approved reviews can still be taken down, and rejected ones reinstated. Nothing moves
+// back to pending except the author editing the review.
+var reviewTransitions = map[string][]string{
+	ReviewPending:  {ReviewApproved, ReviewRejected},
+	ReviewApproved: {ReviewRejected},
+	ReviewRejected: {ReviewApproved},
+}
+
+// ProfanityList holds the words reviews can't contain, in lower case. Words starting or
+// ending with one of them are caught too (see validator.NoWordsFrom), so only the stems
+// need listing; everyday words caught that way are listed as false to allow them.
+var ProfanityList = map[string]bool{
+	"arsehole":     true,
+	"asshole":      true,
+	"bastard":      true,
+	"bitch":        true,
+	"bollocks":     true,
+	"cunt":         true,
+	"dickhead":     true,
+	"fuck":         true,
+	"motherfucker": true,
+	"shit":         true,
+	"twat":         true,
+	"wanker":       true,
+
+	"shitamachi": false, // an old district of Tokyo
+}
+
+// ErrDuplicateReview is returned when a user reviews a movie they've already reviewed.
+var ErrDuplicateReview = errors.New("duplicate review")
+
+// Review is a user's written review of a movie.
+type Review struct {
+	ID             int64      `json:"id"`
+	MovieID        int64      `json:"movie_id"`
+	UserID         int64      `json:"user_id"`
+	Body           string     `json:"body"`
+	Status         string     `json:"status"`
+	ModerationNote string     `json:"moderation_note,omitempty"`
+	ModeratedBy    *int64     `json:"moderated_by,omitempty"`
+	ModeratedAt    *time.Time `json:"moderated_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	Version        int32      `json:"version"`
+}
+
+// ReviewModel struct type which wraps a sql.DB connection pool.
+type ReviewModel struct {
+	DB *sql.DB
+}
+
+func ValidateReview(v *validator.Validator, review *Review) {
+	length := utf8.RuneCountInString(review.Body)
+
+	v.CheckCode(review.Body != "", "body", validator.CodeRequired, "must be provided", nil)
+	v.CheckCode(length >= 20, "body", validator.CodeMinLength, "must be at least 20 characters long", validator.Params{"min": 20})
+	v.CheckCode(length <= 10000, "body", validator.CodeMaxLength, "must not be more than 10000 characters long", validator.Params{"max": 10000})
+	v.CheckCode(validator.NoWordsFrom(review.Body, ProfanityList), "body", validator.CodeProfanity, "must not contain offensive language", nil)
+}
+
+// ValidateModeration checks a moderator may move a review from its current status to
+// status.
+func ValidateModeration(v *validator.Validator, review *Review, status, note string) {
+	allowed := reviewTransitions[review.Status]
+
+	v.CheckCode(validator.In(status, allowed...), "status", validator.CodeNotPermitted, fmt.Sprintf("a %s review can't be moved to %q", review.Status, status), validator.Params{"allowed": allowed})
+	v.CheckCode(utf8.RuneCountInString(note) <= 1000, "note", validator.CodeMaxLength, "must not be more than 1000 characters long", validator.Params{"max": 1000})
+}
+
+const reviewColumns = `id, movie_id, user_id, body, status, moderation_note, moderated_by, moderated_at, created_at, updated_at, version`
+
+func scanReview(row interface{ Scan(...interface{}) error }) (*Review, error) {
+	var review Review
+
+	err := row.Scan(
+		&review.ID,
+		&review.MovieID,
+		&review.UserID,
+		&review.Body,
+		&review.Status,
+		&review.ModerationNote,
+		&review.ModeratedBy,
+		&review.ModeratedAt,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+		&review.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &review, nil
+}
+
+// Insert adds a new, pending, review. It returns ErrDuplicateReview if the user has
+// already reviewed the movie.
+func (m ReviewModel) Insert(review *Review) error {
+	query := `
+		INSERT INTO reviews (movie_id, user_id, body)
+		VALUES ($1, $2, $3)
+		RETURNING id, status, created_at, updated_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, review.MovieID, review.UserID, review.Body).Scan(
+		&review.ID,
+		&review.Status,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+		&review.Version,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrDuplicateReview
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m ReviewModel) Get(id int64) (*Review, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + reviewColumns + `
+		FROM reviews
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	review, err := scanReview(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return review, nil
+}
+
+// Update saves the author's changes to a review and sends it back to pending, clearing
+// any earlier moderation. It returns ErrEditConflict if the review has changed since it
+// was read.
+func (m ReviewModel) Update(review *Review) error {
+	query := `
+		UPDATE reviews
+		SET body = $1, status = 'pending', moderation_note = '', moderated_by = NULL, moderated_at = NULL,
+			updated_at = NOW(), version = version + 1
+		WHERE id = $2 AND version = $3
+		RETURNING ` + reviewColumns
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.save(ctx, review, query, review.Body, review.ID, review.Version)
+}
+
+// Moderate records a moderator's decision on a review. It returns ErrEditConflict if the
+// review has changed since it was read, so a decision is never made on text the
+// moderator hasn't seen.
+func (m ReviewModel) Moderate(review *Review, status, note string, moderatorID int64) error {
+	query := `
+		UPDATE reviews
+		SET status = $1, moderation_note = $2, moderated_by = $3, moderated_at = NOW(), version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING ` + reviewColumns
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.save(ctx, review, query, status, note, moderatorID, review.ID, review.Version)
+}
+
+// save runs an UPDATE ... RETURNING query and copies the result onto review.
+func (m ReviewModel) save(ctx context.Context, review *Review, query string, args ...interface{}) error {
+	updated, err := scanReview(m.DB.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	*review = *updated
+	return nil
+}
+
+func (m ReviewModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM reviews
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAll returns a page of reviews in the given status, for one movie or, if movieID is
+// zero, for every movie.
+func (m ReviewModel) GetAll(movieID int64, status string, filters Filters) ([]*Review, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM reviews
+		WHERE (movie_id = $1 OR $1 = 0)
+		AND status = $2
+		ORDER BY %s %s, id ASC
+		LIMIT $3 OFFSET $4`, reviewColumns, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []*Review{}
+
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		reviews = append(reviews, review)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
@@ -0,0 +1,34 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+// Listed words also match words they begin or end, so a short stem can catch everyday
+// words too. These check the list against both.
+func TestProfanityList(t *testing.T) {
+	caught := []string{
+		"fuck", "Fucking", "fuckers", "motherfucker", "shitty", "bullshit", "bitchy",
+		"bastards", "dickheads", "wankers", "twats", "arseholes", "ASSHOLE",
+	}
+
+	for _, word := range caught {
+		if validator.NoWordsFrom(word, ProfanityList) {
+			t.Errorf("%q wasn't caught", word)
+		}
+	}
+
+	clean := []string{
+		"Scunthorpe", "Dickens", "cocktail", "assess", "class", "classic", "passionate",
+		"Shitamachi", "bitcoin", "Matsushita", "fuchsia", "bollard",
+		"wankel", "Sussex", "Essex", "Penistone", "Clitheroe", "shiitake", "ashitaka",
+	}
+
+	for _, word := range clean {
+		if !validator.NoWordsFrom(word, ProfanityList) {
+			t.Errorf("%q was caught", word)
+		}
+	}
+}
@@ -1,62 +1,186 @@
-package data
-
-import (
-	"errors"
-	"fmt"
-	"strconv"
-	"strings"
-)
-
-// error that our UnmarshalJSON() method can return if we're unable to parse
-// or convert the JSON string successfully.
-var ErrInvalidRUntimeFormat = errors.New("invalid runtime format")
-// Declare a custom Runtime type, which has the underlying type int32 (the same as our
-// Movie struct field).
-
-type Runtime int32
-
-func (r Runtime) MarshalJSON() ([]byte, error) {
-	jsonValue := fmt.Sprintf("%d mins", r)
-
-	quotedJSONVALUE := strconv.Quote(jsonValue)
-
-
-	// convert the quoted string value to a byte slice and return it
-	return []byte(quotedJSONVALUE), nil
-
-}
-
-func (r *Runtime) UnmarshalJSON(jsonValue []byte) error {
-
-	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
-	if err != nil {
-		return ErrInvalidRUntimeFormat
-	}
-	// // Split the string to isolate the part containing the number.
-	parts := strings.Split(unquotedJSONValue, " ")
-
-	// // Sanity check the parts of the string to make sure it was in the expected format.
-	if len(parts) != 2 || parts[1] != "mins" {
-		return ErrInvalidRUntimeFormat
-	}
-
-	// Otherwise, parse the string containing the number into an int32. Again, if this
-	// fails return the ErrInvalidRuntimeFormat error.
-	i, err := strconv.ParseInt(parts[0], 10, 32)
-	if err != nil {
-		return ErrInvalidRUntimeFormat
-
-
-	}
-
-	// Convert the int32 to a Runtime type and assign this to the receiver. Note that we
-	// use the * operator to deference the receiver (which is a pointer to a Runtime
-	// type) in order to set the underlying value of the pointer.
-	*r = Runtime(i)
-
-	return nil
-
-
-}
-
-
+package data
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// error that our UnmarshalJSON() method can return if we're unable to parse
+// or convert the JSON string successfully. Every RuntimeError wraps it, so callers
+// can still check for it with errors.Is().
+var ErrInvalidRUntimeFormat = errors.New("invalid runtime format")
+
+// RuntimeError describes why a runtime value couldn't be parsed.
+type RuntimeError struct {
+	Input  string
+	Reason string
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("invalid runtime %q: %s", e.Input, e.Reason)
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return ErrInvalidRUntimeFormat
+}
+
+// Declare a custom Runtime type, which has the underlying type int32 (the same as our
+// Movie struct field).
+
+type Runtime int32
+
+// RuntimeFormat names one of the ways a runtime can be written in a response.
+type RuntimeFormat string
+
+const (
+	RuntimeFormatMins    RuntimeFormat = "mins"    // "135 mins" (the default)
+	RuntimeFormatISO8601 RuntimeFormat = "iso8601" // "PT2H15M"
+	RuntimeFormatMinutes RuntimeFormat = "minutes" // 135
+)
+
+// RuntimeFormats lists the formats a client can ask for.
+var RuntimeFormats = []string{string(RuntimeFormatMins), string(RuntimeFormatISO8601), string(RuntimeFormatMinutes)}
+
+var (
+	// PT2H15M, PT135M, P0DT2H ... seconds are accepted so long as they make whole minutes.
+	iso8601RX = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+	// 2h 15m, 2h15m, 2 hours, 135 min, 135 mins, 135 minutes ...
+	humanRX = regexp.MustCompile(`^(?:(\d+)\s*h(?:ours?|rs?)?)?\s*(?:(\d+)\s*m(?:in(?:ute)?s?)?)?$`)
+)
+
+// ParseRuntime converts a runtime written as a plain number of minutes ("135"), in
+// minutes or hours and minutes ("135 mins", "2h 15m") or as an ISO 8601 duration
+// ("PT2H15M") into a Runtime. Failures are returned as a *RuntimeError.
+func ParseRuntime(s string) (Runtime, error) {
+	value := strings.TrimSpace(s)
+
+	if value == "" {
+		return 0, &RuntimeError{Input: s, Reason: "must not be empty"}
+	}
+
+	if i, err := strconv.ParseInt(value, 10, 32); err == nil {
+		return Runtime(i), nil
+	}
+
+	upper := strings.ToUpper(value)
+	if strings.HasPrefix(upper, "P") {
+		return parseISO8601Runtime(s, upper)
+	}
+
+	parts := humanRX.FindStringSubmatch(strings.ToLower(value))
+	if parts == nil || (parts[1] == "" && parts[2] == "") {
+		return 0, &RuntimeError{Input: s, Reason: `expected minutes ("135 mins"), hours and minutes ("2h 15m") or an ISO 8601 duration ("PT2H15M")`}
+	}
+
+	return runtimeFromParts(s, "", parts[1], parts[2], "")
+}
+
+func parseISO8601Runtime(input, value string) (Runtime, error) {
+	parts := iso8601RX.FindStringSubmatch(value)
+	if parts == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, &RuntimeError{Input: input, Reason: `not a valid ISO 8601 duration, expected something like "PT2H15M"`}
+	}
+
+	return runtimeFromParts(input, parts[1], parts[2], parts[3], parts[4])
+}
+
+// runtimeFromParts adds up the day, hour, minute and second components (any of which
+// may be empty) and returns the total as a whole number of minutes.
+func runtimeFromParts(input, days, hours, minutes, seconds string) (Runtime, error) {
+	var total int64
+
+	for _, part := range []struct {
+		value      string
+		multiplier int64
+	}{
+		{days, 24 * 60 * 60},
+		{hours, 60 * 60},
+		{minutes, 60},
+		{seconds, 1},
+	} {
+		if part.value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(part.value, 10, 32)
+		if err != nil {
+			return 0, &RuntimeError{Input: input, Reason: "value is too large"}
+		}
+		total += n * part.multiplier
+	}
+
+	if total%60 != 0 {
+		return 0, &RuntimeError{Input: input, Reason: "must be a whole number of minutes"}
+	}
+
+	if total/60 > int64(^uint32(0)>>1) {
+		return 0, &RuntimeError{Input: input, Reason: "value is too large"}
+	}
+
+	return Runtime(total / 60), nil
+}
+
+// Format returns the runtime in the given format, ready to be marshalled as JSON. An
+// unknown format falls back to "N mins".
+func (r Runtime) Format(f RuntimeFormat) interface{} {
+	switch f {
+	case RuntimeFormatMinutes:
+		return int32(r)
+	case RuntimeFormatISO8601:
+		hours, minutes := r/60, r%60
+		switch {
+		case hours == 0:
+			return fmt.Sprintf("PT%dM", minutes)
+		case minutes == 0:
+			return fmt.Sprintf("PT%dH", hours)
+		default:
+			return fmt.Sprintf("PT%dH%dM", hours, minutes)
+		}
+	default:
+		return fmt.Sprintf("%d mins", r)
+	}
+}
+
+func (r Runtime) MarshalJSON() ([]byte, error) {
+	jsonValue := r.Format(RuntimeFormatMins).(string)
+
+	quotedJSONVALUE := strconv.Quote(jsonValue)
+
+
+	// convert the quoted string value to a byte slice and return it
+	return []byte(quotedJSONVALUE), nil
+
+}
+
+// UnmarshalJSON accepts either a JSON number of minutes or a string in any of the
+// formats understood by ParseRuntime.
+func (r *Runtime) UnmarshalJSON(jsonValue []byte) error {
+
+	if i, err := strconv.ParseInt(string(jsonValue), 10, 32); err == nil {
+		*r = Runtime(i)
+		return nil
+	}
+
+	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
+	if err != nil {
+		return &RuntimeError{Input: string(jsonValue), Reason: "must be a string or a whole number of minutes"}
+	}
+
+	runtime, err := ParseRuntime(unquotedJSONValue)
+	if err != nil {
+		return err
+	}
+
+	// Note that we use the * operator to deference the receiver (which is a pointer to
+	// a Runtime type) in order to set the underlying value of the pointer.
+	*r = runtime
+
+	return nil
+
+
+}
+
+
@@ -0,0 +1,138 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseRuntime(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Runtime
+	}{
+		{"135", 135},
+		{" 135 ", 135},
+		{"0", 0},
+		{"135 min", 135},
+		{"135 mins", 135},
+		{"135 minutes", 135},
+		{"135m", 135},
+		{"2h 15m", 135},
+		{"2h15m", 135},
+		{"2 hours", 120},
+		{"1 hour", 60},
+		{"2 hrs 15 mins", 135},
+		{"2H 15M", 135},
+		{"PT2H15M", 135},
+		{"pt2h15m", 135},
+		{"PT135M", 135},
+		{"PT2H", 120},
+		{"P0DT2H", 120},
+		{"P1D", 1440},
+		{"PT3600S", 60},
+		{"PT1H30M0S", 90},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRuntime(tt.input)
+		if err != nil {
+			t.Errorf("ParseRuntime(%q) returned error: %v", tt.input, err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("ParseRuntime(%q) = %d; want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseRuntimeErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"   ",
+		"P",
+		"PT",
+		"P1DT",
+		"PT30S",
+		"PT1H30S",
+		"PT1.5H",
+		"P1Y",
+		"P99999999999D",
+		"PT99999999999M",
+		"P2000000D",
+		"99999999999",
+		"99999999999 mins",
+		"abc",
+		"2 days",
+		"h",
+		"-",
+		"1h 2h",
+	}
+
+	for _, input := range tests {
+		_, err := ParseRuntime(input)
+
+		var rerr *RuntimeError
+		if !errors.As(err, &rerr) {
+			t.Errorf("ParseRuntime(%q) returned %v; want a *RuntimeError", input, err)
+			continue
+		}
+
+		if !errors.Is(err, ErrInvalidRUntimeFormat) {
+			t.Errorf("ParseRuntime(%q) error doesn't wrap ErrInvalidRUntimeFormat", input)
+		}
+	}
+}
+
+func TestRuntimeFormat(t *testing.T) {
+	tests := []struct {
+		runtime Runtime
+		format  RuntimeFormat
+		want    interface{}
+	}{
+		{135, RuntimeFormatMins, "135 mins"},
+		{135, RuntimeFormatMinutes, int32(135)},
+		{135, RuntimeFormatISO8601, "PT2H15M"},
+		{120, RuntimeFormatISO8601, "PT2H"},
+		{45, RuntimeFormatISO8601, "PT45M"},
+		{0, RuntimeFormatISO8601, "PT0M"},
+		{135, RuntimeFormat("unknown"), "135 mins"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.runtime.Format(tt.format); got != tt.want {
+			t.Errorf("Runtime(%d).Format(%q) = %#v; want %#v", tt.runtime, tt.format, got, tt.want)
+		}
+	}
+}
+
+// A runtime written in any of the formats, or marshalled as JSON, reads back the same.
+func TestRuntimeRoundTrip(t *testing.T) {
+	for _, runtime := range []Runtime{0, 1, 59, 60, 61, 135, 1440, 100000} {
+		for _, format := range RuntimeFormats {
+			input := fmt.Sprint(runtime.Format(RuntimeFormat(format)))
+
+			got, err := ParseRuntime(input)
+			if err != nil {
+				t.Errorf("ParseRuntime(%q) returned error: %v", input, err)
+				continue
+			}
+
+			if got != runtime {
+				t.Errorf("ParseRuntime(%q) = %d; want %d", input, got, runtime)
+			}
+		}
+
+		js, err := runtime.MarshalJSON()
+		if err != nil {
+			t.Errorf("Runtime(%d).MarshalJSON() returned error: %v", runtime, err)
+			continue
+		}
+
+		var got Runtime
+		if err := got.UnmarshalJSON(js); err != nil || got != runtime {
+			t.Errorf("UnmarshalJSON(%s) = %d, %v; want %d", js, got, err, runtime)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MovieStats summarises the catalogue, or the part of it added within a date range.
+type MovieStats struct {
+	Totals          StatsTotals   `json:"totals"`
+	PerYear         []*YearStats  `json:"per_year"`
+	PerGenre        []*GenreStats `json:"per_genre"`
+	RecentAdditions []*MovieTitle `json:"recent_additions"`
+}
+
+type StatsTotals struct {
+	Movies         int64   `json:"movies"`
+	Genres         int64   `json:"genres"`
+	Ratings        int64   `json:"ratings"`
+	AverageRuntime float64 `json:"average_runtime"`
+}
+
+type YearStats struct {
+	Year  int32 `json:"year"`
+	Count int64 `json:"count"`
+}
+
+type GenreStats struct {
+	Genre          string  `json:"genre"`
+	Count          int64   `json:"count"`
+	AverageRuntime float64 `json:"average_runtime"`
+}
+
+// recentAdditions is how many of the newest movies MovieStats lists.
+const recentAdditions = 10
+
+// StatsModel struct type which wraps a sql.DB connection pool.
+type StatsModel struct {
+	DB *sql.DB
+}
+
+// Movies works out the catalogue statistics for the movies added between after and
+// before; either can be zero for an open-ended range. The aggregates are all read in one
+// snapshot so they add up.
+func (m StatsModel) Movies(after, before time.Time) (*MovieStats, error) {
+	where, args, _ := MovieFilter{CreatedAfter: after, CreatedBefore: before}.where()
+
+	stats := &MovieStats{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT count(*), coalesce(sum(rating_count), 0), coalesce(round(avg(runtime), 1), 0),
+			(SELECT count(DISTINCT genre) FROM movies, unnest(genres) AS genre ` + where + `)
+		FROM movies
+		` + where
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
+		&stats.Totals.Movies,
+		&stats.Totals.Ratings,
+		&stats.Totals.AverageRuntime,
+		&stats.Totals.Genres,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT year, count(*)
+		FROM movies
+		` + where + `
+		GROUP BY year
+		ORDER BY year`
+
+	stats.PerYear = []*YearStats{}
+
+	err = scanRows(ctx, tx, query, args, func(rows *sql.Rows) error {
+		var year YearStats
+		if err := rows.Scan(&year.Year, &year.Count); err != nil {
+			return err
+		}
+		stats.PerYear = append(stats.PerYear, &year)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT genre, count(*), round(avg(runtime), 1)
+		FROM movies, unnest(genres) AS genre
+		` + where + `
+		GROUP BY genre
+		ORDER BY count(*) DESC, genre`
+
+	stats.PerGenre = []*GenreStats{}
+
+	err = scanRows(ctx, tx, query, args, func(rows *sql.Rows) error {
+		var genre GenreStats
+		if err := rows.Scan(&genre.Genre, &genre.Count, &genre.AverageRuntime); err != nil {
+			return err
+		}
+		stats.PerGenre = append(stats.PerGenre, &genre)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	query = fmt.Sprintf(`
+		SELECT id, title, year
+		FROM movies
+		%s
+		ORDER BY created_at DESC, id DESC
+		LIMIT %d`, where, recentAdditions)
+
+	stats.RecentAdditions = []*MovieTitle{}
+
+	err = scanRows(ctx, tx, query, args, func(rows *sql.Rows) error {
+		var title MovieTitle
+		if err := rows.Scan(&title.ID, &title.Title, &title.Year); err != nil {
+			return err
+		}
+		stats.RecentAdditions = append(stats.RecentAdditions, &title)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// scanRows runs a query in tx and calls scan for each row.
+func scanRows(ctx context.Context, tx *sql.Tx, query string, args []interface{}, scan func(rows *sql.Rows) error) error {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
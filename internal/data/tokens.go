@@ -0,0 +1,97 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"time"
+
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+// Token scopes.
+const (
+	ScopeAuthentication = "authentication"
+)
+
+// Token is a bearer token. Only its SHA-256 hash is stored; the plaintext is sent to the
+// client once, when the token is created.
+type Token struct {
+	Plaintext string    `json:"token"`
+	Hash      []byte    `json:"-"`
+	UserID    int64     `json:"-"`
+	Expiry    time.Time `json:"expiry"`
+	Scope     string    `json:"-"`
+}
+
+func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token := &Token{
+		UserID: userID,
+		Expiry: time.Now().Add(ttl),
+		Scope:  scope,
+	}
+
+	// 16 random bytes give a 26 character token once base32 encoded without padding.
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Plaintext = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+
+	hash := sha256.Sum256([]byte(token.Plaintext))
+	token.Hash = hash[:]
+
+	return token, nil
+}
+
+// ValidateTokenPlaintext checks a token has the shape of one we'd have issued.
+func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string) {
+	v.CheckCode(tokenPlaintext != "", "token", validator.CodeRequired, "must be provided", nil)
+	v.CheckCode(len(tokenPlaintext) == 26, "token", validator.CodeInvalid, "must be 26 bytes long", nil)
+}
+
+// TokenModel struct type which wraps a sql.DB connection pool.
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// New creates a token for the user and stores it.
+func (m TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(token)
+	return token, err
+}
+
+func (m TokenModel) Insert(token *Token) error {
+	query := `
+		INSERT INTO tokens (hash, user_id, expiry, scope)
+		VALUES ($1, $2, $3, $4)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, token.Hash, token.UserID, token.Expiry, token.Scope)
+	return err
+}
+
+// DeleteAllForUser removes every token with the given scope belonging to the user.
+func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
+	query := `
+		DELETE FROM tokens
+		WHERE scope = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, scope, userID)
+	return err
+}
@@ -0,0 +1,201 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"github.com/goddhi/zeliz-movie/internal/validator"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrDuplicateEmail is returned when registering an email address that's already in use.
+var ErrDuplicateEmail = errors.New("duplicate email")
+
+// AnonymousUser represents a client that hasn't authenticated.
+var AnonymousUser = &User{}
+
+// User is someone with an account, e.g. so that they can rate movies.
+type User struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Password  password  `json:"-"`
+	Version   int       `json:"-"`
+}
+
+// IsAnonymous reports whether the user is the AnonymousUser.
+func (u *User) IsAnonymous() bool {
+	return u == AnonymousUser
+}
+
+// password holds the plaintext password, when we have it, alongside its bcrypt hash.
+// The plaintext is a pointer so a missing password can be told apart from "".
+type password struct {
+	plaintext *string
+	hash      []byte
+}
+
+// Set hashes the plaintext password and stores both versions.
+func (p *password) Set(plaintextPassword string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	p.plaintext = &plaintextPassword
+	p.hash = hash
+
+	return nil
+}
+
+// Matches reports whether the plaintext password matches the stored hash.
+func (p *password) Matches(plaintextPassword string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
+	if err != nil {
+		switch {
+		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func ValidateEmail(v *validator.Validator, email string) {
+	v.CheckCode(email != "", "email", validator.CodeRequired, "must be provided", nil)
+	v.CheckCode(validator.Matches(email, validator.EmailRX), "email", validator.CodeInvalid, "must be a valid email address", nil)
+}
+
+func ValidatePasswordPlaintext(v *validator.Validator, password string) {
+	v.CheckCode(password != "", "password", validator.CodeRequired, "must be provided", nil)
+	v.CheckCode(len(password) >= 8, "password", validator.CodeMinBytes, "must be at least 8 bytes long", validator.Params{"min": 8})
+	v.CheckCode(len(password) <= 72, "password", validator.CodeMaxBytes, "must not be more than 72 bytes long", validator.Params{"max": 72})
+}
+
+// ValidateUserDetails checks the user's name and email, which can be done before the
+// password is hashed.
+func ValidateUserDetails(v *validator.Validator, user *User) {
+	v.CheckCode(user.Name != "", "name", validator.CodeRequired, "must be provided", nil)
+	v.CheckCode(utf8.RuneCountInString(user.Name) <= 500, "name", validator.CodeMaxLength, "must not be more than 500 characters long", validator.Params{"max": 500})
+
+	ValidateEmail(v, user.Email)
+}
+
+func ValidateUser(v *validator.Validator, user *User) {
+	ValidateUserDetails(v, user)
+
+	if user.Password.plaintext != nil {
+		ValidatePasswordPlaintext(v, *user.Password.plaintext)
+	}
+
+	// A missing hash is a bug in our code rather than a problem with the request.
+	if user.Password.hash == nil {
+		panic("missing password hash for user")
+	}
+}
+
+// UserModel struct type which wraps a sql.DB connection pool.
+type UserModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a new user, returning ErrDuplicateEmail if the email is already taken.
+func (m UserModel) Insert(user *User) error {
+	query := `
+		INSERT INTO users (name, email, password_hash)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, user.Name, user.Email, user.Password.hash).Scan(&user.ID, &user.CreatedAt, &user.Version)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "users_email_key" {
+			return ErrDuplicateEmail
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m UserModel) GetByEmail(email string) (*User, error) {
+	query := `
+		SELECT id, created_at, name, email, password_hash, version
+		FROM users
+		WHERE email = $1`
+
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, email).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+// GetForToken returns the user a plaintext token with the given scope belongs to,
+// provided the token hasn't expired.
+func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.version
+		FROM users
+		INNER JOIN tokens ON users.id = tokens.user_id
+		WHERE tokens.hash = $1
+		AND tokens.scope = $2
+		AND tokens.expiry > $3`
+
+	args := []interface{}{tokenHash[:], tokenScope, time.Now()}
+
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,138 @@
+// Package filter parses the expressions accepted by the filter query string parameter,
+// such as
+//
+//	year>=1990 AND (genres HAS "drama" OR runtime<90)
+//
+// into a syntax tree. Only the fields and operators the caller allows are accepted, so
+// a tree that parses can be compiled into SQL without further checks on its structure.
+// Turning the tree into a query is left to the store; see data.MovieFilter.
+package filter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+// Type is the type of a field, which decides the operators and values it takes.
+type Type int
+
+const (
+	Integer Type = iota
+	Float
+	Text
+	TextList
+	Timestamp
+)
+
+// Fields maps the names that can be used in an expression to their types.
+type Fields map[string]Type
+
+// Operators lists the operators each type of field can be compared with. "~" is a case
+// insensitive substring match, and "HAS" tests whether a list contains a value.
+var Operators = map[Type][]string{
+	Integer:   {"=", "!=", "<", "<=", ">", ">="},
+	Float:     {"=", "!=", "<", "<=", ">", ">="},
+	Timestamp: {"=", "!=", "<", "<=", ">", ">="},
+	Text:      {"=", "!=", "~"},
+	TextList:  {"HAS"},
+}
+
+// Limits on the size of an expression, so a single request can't produce an
+// unreasonably expensive query.
+const (
+	MaxLength      = 2000
+	MaxComparisons = 50
+	MaxDepth       = 20
+)
+
+// Node is a node in the syntax tree: an *And, *Or, *Not or *Comparison.
+type Node interface {
+	node()
+}
+
+// And matches when both sides match.
+type And struct {
+	Left, Right Node
+}
+
+// Or matches when either side matches.
+type Or struct {
+	Left, Right Node
+}
+
+// Not matches when Expr doesn't.
+type Not struct {
+	Expr Node
+}
+
+// Comparison compares a field with a value. The value's Go type follows the field's:
+// int64 for Integer, float64 for Float, time.Time for Timestamp and string otherwise.
+type Comparison struct {
+	Field    string
+	Operator string
+	Value    interface{}
+	Position int
+}
+
+func (*And) node()        {}
+func (*Or) node()         {}
+func (*Not) node()        {}
+func (*Comparison) node() {}
+
+// Inspect calls fn for every comparison in the tree, from left to right.
+func Inspect(node Node, fn func(c *Comparison)) {
+	switch n := node.(type) {
+	case *And:
+		Inspect(n.Left, fn)
+		Inspect(n.Right, fn)
+	case *Or:
+		Inspect(n.Left, fn)
+		Inspect(n.Right, fn)
+	case *Not:
+		Inspect(n.Expr, fn)
+	case *Comparison:
+		fn(n)
+	}
+}
+
+// Error describes why an expression was rejected. Position is the 1-based character
+// offset of the offending token, and Code is the validator code to report it with.
+type Error struct {
+	Code     string
+	Message  string
+	Position int
+	Token    string
+	Field    string
+	Limit    int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Message, e.Position)
+}
+
+// Params returns the values to record with the error in a validator.
+func (e *Error) Params() validator.Params {
+	params := validator.Params{"position": e.Position, "token": e.Token}
+	if e.Field != "" {
+		params["field"] = e.Field
+	}
+	if e.Limit != 0 {
+		params["max"] = e.Limit
+	}
+	return params
+}
+
+// ParseTimestamp parses a timestamp written as RFC 3339, or as a plain YYYY-MM-DD date
+// meaning midnight UTC. It's also used for the created_after and created_before
+// parameters, so they and filter expressions always accept the same values.
+func ParseTimestamp(s string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,341 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenHas
+)
+
+// keywords are matched case insensitively.
+var keywords = map[string]tokenKind{
+	"AND": tokenAnd,
+	"OR":  tokenOr,
+	"NOT": tokenNot,
+	"HAS": tokenHas,
+}
+
+// token is a lexed token. Text is the token as written, and Value the unquoted contents
+// of a string.
+type token struct {
+	kind     tokenKind
+	text     string
+	value    string
+	position int
+}
+
+// display returns the token as it should be shown in an error.
+func (t token) display() string {
+	if t.kind == tokenEOF && t.text == "" {
+		return "end of input"
+	}
+	return t.text
+}
+
+func syntaxError(t token, message string) *Error {
+	return &Error{Code: validator.CodeSyntax, Message: message, Position: t.position, Token: t.display()}
+}
+
+// lex splits the input into tokens. Positions count characters from 1.
+func lex(input string) ([]token, error) {
+	runes := []rune(input)
+
+	var tokens []token
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+
+		case r == '(' || r == ')':
+			kind := tokenLParen
+			if r == ')' {
+				kind = tokenRParen
+			}
+			i++
+			tokens = append(tokens, token{kind: kind, text: string(r), position: start + 1})
+
+		case r == '=' || r == '~':
+			i++
+			tokens = append(tokens, token{kind: tokenOperator, text: string(r), position: start + 1})
+
+		case r == '<' || r == '>' || r == '!':
+			i++
+			if i < len(runes) && runes[i] == '=' {
+				i++
+			} else if r == '!' {
+				return nil, syntaxError(token{text: "!", position: start + 1}, `expected "=" after "!"`)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: string(runes[start:i]), position: start + 1})
+
+		case r == '"':
+			var value strings.Builder
+
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, syntaxError(token{text: string(runes[start:]), position: start + 1}, "unterminated string")
+				}
+				if runes[i] == '"' {
+					i++
+					break
+				}
+				if runes[i] == '\\' {
+					if i+1 >= len(runes) || (runes[i+1] != '"' && runes[i+1] != '\\') {
+						return nil, syntaxError(token{text: string(runes[i:min(i+2, len(runes))]), position: i + 1}, `only \" and \\ can be escaped`)
+					}
+					i++
+				}
+				value.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenString, text: string(runes[start:i]), value: value.String(), position: start + 1})
+
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			i++
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			if i+1 < len(runes) && runes[i] == '.' && unicode.IsDigit(runes[i+1]) {
+				i++
+				for i < len(runes) && unicode.IsDigit(runes[i]) {
+					i++
+				}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), position: start + 1})
+
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			text := string(runes[start:i])
+			kind, ok := keywords[strings.ToUpper(text)]
+			if !ok {
+				kind = tokenIdent
+			}
+			tokens = append(tokens, token{kind: kind, text: text, position: start + 1})
+
+		default:
+			return nil, syntaxError(token{text: string(r), position: start + 1}, fmt.Sprintf("unexpected character %q", r))
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, position: len(runes) + 1}), nil
+}
+
+// Parse parses an expression, accepting only the given fields. AND binds more tightly
+// than OR, and NOT more tightly than either. The error returned for a rejected
+// expression is always an *Error.
+func Parse(input string, fields Fields) (Node, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, fields: fields}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, syntaxError(t, fmt.Sprintf("unexpected %s, expected AND, OR or the end of the expression", t.display()))
+	}
+
+	return node, nil
+}
+
+type parser struct {
+	tokens      []token
+	next        int
+	fields      Fields
+	depth       int
+	comparisons int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenOr {
+		p.advance()
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &Or{Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenAnd {
+		p.advance()
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &And{Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	t := p.peek()
+
+	switch t.kind {
+	case tokenNot, tokenLParen:
+		p.depth++
+		if p.depth > MaxDepth {
+			return nil, syntaxError(t, fmt.Sprintf("expression is nested more than %d deep", MaxDepth))
+		}
+		defer func() { p.depth-- }()
+	}
+
+	switch t.kind {
+	case tokenNot:
+		p.advance()
+
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &Not{Expr: expr}, nil
+
+	case tokenLParen:
+		p.advance()
+
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := p.advance(); closing.kind != tokenRParen {
+			return nil, syntaxError(closing, fmt.Sprintf(`unexpected %s, expected ")"`, closing.display()))
+		}
+
+		return expr, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Node, error) {
+	field := p.advance()
+	if field.kind != tokenIdent {
+		return nil, syntaxError(field, fmt.Sprintf("unexpected %s, expected a field name", field.display()))
+	}
+
+	typ, ok := p.fields[field.text]
+	if !ok {
+		return nil, &Error{Code: validator.CodeUnknownField, Message: fmt.Sprintf("%s is not a field you can filter on", field.text), Position: field.position, Token: field.text}
+	}
+
+	operator := p.advance()
+	if operator.kind != tokenOperator && operator.kind != tokenHas {
+		return nil, syntaxError(operator, fmt.Sprintf("unexpected %s, expected an operator after %s", operator.display(), field.text))
+	}
+
+	op := operator.text
+	if operator.kind == tokenHas {
+		op = "HAS"
+	}
+
+	if !validator.In(op, Operators[typ]...) {
+		return nil, &Error{Code: validator.CodeOperatorNotAllowed, Message: fmt.Sprintf("%s can't be used with %s", operator.text, field.text), Position: operator.position, Token: operator.text, Field: field.text}
+	}
+
+	t := p.advance()
+	if t.kind != tokenNumber && t.kind != tokenString {
+		return nil, syntaxError(t, fmt.Sprintf("unexpected %s, expected a value after %s", t.display(), operator.text))
+	}
+
+	value, ok := p.value(typ, t)
+	if !ok {
+		return nil, &Error{Code: validator.CodeInvalidValue, Message: fmt.Sprintf("%s is not a valid value for %s", t.text, field.text), Position: t.position, Token: t.text, Field: field.text}
+	}
+
+	p.comparisons++
+	if p.comparisons > MaxComparisons {
+		return nil, &Error{Code: validator.CodeMaxItems, Message: fmt.Sprintf("must not contain more than %d comparisons", MaxComparisons), Position: field.position, Token: field.text, Limit: MaxComparisons}
+	}
+
+	return &Comparison{Field: field.text, Operator: op, Value: value, Position: field.position}, nil
+}
+
+// value converts a value token to the Go type for the field's type.
+func (p *parser) value(typ Type, t token) (interface{}, bool) {
+	switch typ {
+	case Integer:
+		if t.kind != tokenNumber {
+			return nil, false
+		}
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		return n, err == nil
+
+	case Float:
+		if t.kind != tokenNumber {
+			return nil, false
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		return f, err == nil
+
+	case Timestamp:
+		if t.kind != tokenString {
+			return nil, false
+		}
+		return ParseTimestamp(t.value)
+
+	default:
+		return t.value, t.kind == tokenString
+	}
+}
@@ -0,0 +1,179 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+var testFields = Fields{
+	"year":    Integer,
+	"rating":  Float,
+	"title":   Text,
+	"genres":  TextList,
+	"created": Timestamp,
+}
+
+// show renders a tree with every node bracketed, so the shape of the tree is visible.
+func show(node Node) string {
+	switch n := node.(type) {
+	case *And:
+		return "(" + show(n.Left) + " AND " + show(n.Right) + ")"
+	case *Or:
+		return "(" + show(n.Left) + " OR " + show(n.Right) + ")"
+	case *Not:
+		return "NOT " + show(n.Expr)
+	case *Comparison:
+		return fmt.Sprintf("%s %s %v", n.Field, n.Operator, n.Value)
+	}
+	return fmt.Sprintf("%T", node)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`year = 1999`, `year = 1999`},
+		{`year>=1990`, `year >= 1990`},
+		{`year != -5`, `year != -5`},
+		{`rating > 7.5`, `rating > 7.5`},
+		{`title ~ "star"`, `title ~ star`},
+		{`genres has "drama"`, `genres HAS drama`},
+
+		// AND binds more tightly than OR, and NOT more tightly than either.
+		{`year = 1 OR year = 2 AND year = 3`, `(year = 1 OR (year = 2 AND year = 3))`},
+		{`year = 1 AND year = 2 OR year = 3`, `((year = 1 AND year = 2) OR year = 3)`},
+		{`NOT year = 1 AND year = 2`, `(NOT year = 1 AND year = 2)`},
+		{`year = 1 OR year = 2 OR year = 3`, `((year = 1 OR year = 2) OR year = 3)`},
+
+		// Parentheses override precedence.
+		{`(year = 1 OR year = 2) AND year = 3`, `((year = 1 OR year = 2) AND year = 3)`},
+		{`NOT (year = 1 OR year = 2)`, `NOT (year = 1 OR year = 2)`},
+		{`((year = 1))`, `year = 1`},
+
+		// Keywords are case insensitive.
+		{`year = 1 and not year = 2`, `(year = 1 AND NOT year = 2)`},
+
+		// Only \" and \\ can be escaped in a string.
+		{`title = "say \"hi\""`, `title = say "hi"`},
+		{`title = "back\\slash"`, `title = back\slash`},
+		{`title = "été"`, `title = été`},
+	}
+
+	for _, tt := range tests {
+		node, err := Parse(tt.input, testFields)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", tt.input, err)
+			continue
+		}
+
+		if got := show(node); got != tt.want {
+			t.Errorf("Parse(%q) = %s; want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	node, err := Parse(`created > "2024-01-02"`, testFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	if got := node.(*Comparison).Value.(time.Time); !got.Equal(want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		code     string
+		position int
+		token    string
+	}{
+		{`AND year = 1`, validator.CodeSyntax, 1, "AND"},
+		{`budget > 10`, validator.CodeUnknownField, 1, "budget"},
+		{`year = 1 AND budget > 10`, validator.CodeUnknownField, 14, "budget"},
+		{`title > "a"`, validator.CodeOperatorNotAllowed, 7, ">"},
+		{`genres = "drama"`, validator.CodeOperatorNotAllowed, 8, "="},
+		{`year = "1999"`, validator.CodeInvalidValue, 8, `"1999"`},
+		{`title = 5`, validator.CodeInvalidValue, 9, "5"},
+		{`created > "yesterday"`, validator.CodeInvalidValue, 11, `"yesterday"`},
+		{`year = 99999999999999999999`, validator.CodeInvalidValue, 8, "99999999999999999999"},
+		{`year = `, validator.CodeSyntax, 8, "end of input"},
+		{`year 1999`, validator.CodeSyntax, 6, "1999"},
+		{`year = 1 year = 2`, validator.CodeSyntax, 10, "year"},
+		{`(year = 1`, validator.CodeSyntax, 10, "end of input"},
+		{`year = 1)`, validator.CodeSyntax, 9, ")"},
+		{`year ! 1`, validator.CodeSyntax, 6, "!"},
+		{`year = 1 & year = 2`, validator.CodeSyntax, 10, "&"},
+		{`title = "open`, validator.CodeSyntax, 9, `"open`},
+		{`title = "\n"`, validator.CodeSyntax, 10, `\n`},
+
+		// Positions count characters, not bytes.
+		{`title = "été" AND budget > 1`, validator.CodeUnknownField, 19, "budget"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input, testFields)
+
+		var ferr *Error
+		if !errors.As(err, &ferr) {
+			t.Errorf("Parse(%q) returned %v; want an *Error", tt.input, err)
+			continue
+		}
+
+		if ferr.Code != tt.code || ferr.Position != tt.position || ferr.Token != tt.token {
+			t.Errorf("Parse(%q) = %s at %d (%q); want %s at %d (%q)", tt.input, ferr.Code, ferr.Position, ferr.Token, tt.code, tt.position, tt.token)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	nested := func(depth int) string {
+		return strings.Repeat("(", depth) + "year = 1" + strings.Repeat(")", depth)
+	}
+	nots := func(depth int) string {
+		return strings.Repeat("NOT ", depth) + "year = 1"
+	}
+	comparisons := func(n int) string {
+		parts := make([]string, n)
+		for i := range parts {
+			parts[i] = "year = 1"
+		}
+		return strings.Join(parts, " OR ")
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		code  string
+	}{
+		{"nested to MaxDepth", nested(MaxDepth), ""},
+		{"nested past MaxDepth", nested(MaxDepth + 1), validator.CodeSyntax},
+		{"NOT to MaxDepth", nots(MaxDepth), ""},
+		{"NOT past MaxDepth", nots(MaxDepth + 1), validator.CodeSyntax},
+		{"MaxComparisons", comparisons(MaxComparisons), ""},
+		{"past MaxComparisons", comparisons(MaxComparisons + 1), validator.CodeMaxItems},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input, testFields)
+
+		switch {
+		case tt.code == "" && err != nil:
+			t.Errorf("%s: returned error: %v", tt.name, err)
+		case tt.code != "":
+			var ferr *Error
+			if !errors.As(err, &ferr) || ferr.Code != tt.code {
+				t.Errorf("%s: returned %v; want an error with code %s", tt.name, err, tt.code)
+			}
+		}
+	}
+}
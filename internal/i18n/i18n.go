@@ -0,0 +1,232 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goddhi/zeliz-movie/internal/validator"
+)
+
+// DefaultLocale is used when the client doesn't ask for (or we don't ship) a language.
+const DefaultLocale = "en"
+
+// Codes for the standard error responses sent from cmd/api/errors.go.
+const (
+	MsgServerError      = "server_error"
+	MsgNotFound         = "not_found"
+	MsgMethodNotAllowed = "method_not_allowed"
+	MsgEditConflict     = "edit_conflict"
+
+	MsgIdempotencyKeyReused     = "idempotency_key_reused"
+	MsgIdempotencyKeyInProgress = "idempotency_key_in_progress"
+
+	MsgGenreInUse = "genre_in_use"
+
+	MsgInvalidCredentials         = "invalid_credentials"
+	MsgInvalidAuthenticationToken = "invalid_authentication_token"
+	MsgAuthenticationRequired     = "authentication_required"
+	MsgRatingExists               = "rating_exists"
+	MsgNotPermitted               = "forbidden"
+	MsgReviewExists               = "review_exists"
+
+	MsgRateLimitExceeded = "rate_limit_exceeded"
+
+	MsgBadRequest         = "bad_request"
+	MsgBodyMalformed      = "body_malformed"
+	MsgBodyMalformedAt    = "body_malformed_at"
+	MsgBodyWrongType      = "body_wrong_type"
+	MsgBodyWrongTypeAt    = "body_wrong_type_at"
+	MsgBodyEmpty          = "body_empty"
+	MsgBodyUnknownKey     = "body_unknown_key"
+	MsgBodyTooLarge       = "body_too_large"
+	MsgBodyMultipleValues = "body_multiple_values"
+)
+
+// ResponseCodes lists every code above.
+var ResponseCodes = []string{
+	MsgServerError,
+	MsgNotFound,
+	MsgMethodNotAllowed,
+	MsgEditConflict,
+	MsgIdempotencyKeyReused,
+	MsgIdempotencyKeyInProgress,
+	MsgGenreInUse,
+	MsgInvalidCredentials,
+	MsgInvalidAuthenticationToken,
+	MsgAuthenticationRequired,
+	MsgRatingExists,
+	MsgNotPermitted,
+	MsgReviewExists,
+	MsgRateLimitExceeded,
+	MsgBadRequest,
+	MsgBodyMalformed,
+	MsgBodyMalformedAt,
+	MsgBodyWrongType,
+	MsgBodyWrongTypeAt,
+	MsgBodyEmpty,
+	MsgBodyUnknownKey,
+	MsgBodyTooLarge,
+	MsgBodyMultipleValues,
+}
+
+// Locales returns the shipped locales in a stable order.
+func Locales() []string {
+	locales := make([]string, 0, len(catalogues))
+	for locale := range catalogues {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Negotiate picks the best shipped locale for an Accept-Language header value. Tags are
+// matched on their primary language (so "fr-CA" gets "fr") in order of their q-value,
+// falling back to DefaultLocale.
+func Negotiate(acceptLanguage string) string {
+	type tag struct {
+		lang string
+		q    float64
+	}
+
+	var tags []tag
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		lang := strings.ToLower(strings.TrimSpace(fields[0]))
+		if lang == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				f, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err == nil {
+					q = f
+				}
+			}
+		}
+
+		if q > 0 {
+			tags = append(tags, tag{lang: lang, q: q})
+		}
+	}
+
+	// SliceStable keeps the client's order for tags with the same q-value.
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	for _, t := range tags {
+		if t.lang == "*" {
+			return DefaultLocale
+		}
+
+		primary, _, _ := strings.Cut(t.lang, "-")
+		if _, ok := catalogues[primary]; ok {
+			return primary
+		}
+	}
+
+	return DefaultLocale
+}
+
+// Message renders the catalogue message for code in the given locale, substituting
+// {name} placeholders with params. It falls back to English, and then to the code
+// itself if nothing is found.
+func Message(locale, code string, params validator.Params) string {
+	template, ok := catalogues[locale][code]
+	if !ok {
+		template, ok = catalogues[DefaultLocale][code]
+		if !ok {
+			return code
+		}
+	}
+
+	return render(template, params)
+}
+
+// Localize returns copies of a validator's Errors and Details maps with the messages
+// translated into locale. English keeps the wording supplied by each check, which is
+// more specific than the generic catalogue entry for its code.
+func Localize(locale string, v *validator.Validator) (map[string]string, map[string][]validator.FieldError) {
+	errors := make(map[string]string, len(v.Errors))
+	details := make(map[string][]validator.FieldError, len(v.Details))
+
+	for key, fieldErrors := range v.Details {
+		translated := make([]validator.FieldError, len(fieldErrors))
+
+		for i, fe := range fieldErrors {
+			translated[i] = fe
+			translated[i].Message = localizeFieldError(locale, fe)
+		}
+
+		details[key] = translated
+	}
+
+	for key, message := range v.Errors {
+		if fieldErrors := details[key]; len(fieldErrors) > 0 {
+			message = fieldErrors[0].Message
+		}
+		errors[key] = message
+	}
+
+	return errors, details
+}
+
+func localizeFieldError(locale string, fe validator.FieldError) string {
+	if locale == DefaultLocale && fe.Message != "" {
+		return fe.Message
+	}
+
+	if template, ok := catalogues[locale][fe.Code]; ok {
+		return render(template, fe.Params)
+	}
+
+	if fe.Message != "" {
+		return fe.Message
+	}
+
+	return Message(DefaultLocale, fe.Code, fe.Params)
+}
+
+// Missing reports every validation and response code that lacks a message in one of
+// the shipped locales, as "locale: code" strings.
+func Missing() []string {
+	var missing []string
+
+	codes := append(append([]string{}, validator.Codes...), ResponseCodes...)
+
+	for _, locale := range Locales() {
+		for _, code := range codes {
+			if _, ok := catalogues[locale][code]; !ok {
+				missing = append(missing, locale+": "+code)
+			}
+		}
+	}
+
+	return missing
+}
+
+func render(template string, params validator.Params) string {
+	if len(params) == 0 {
+		return template
+	}
+
+	pairs := make([]string, 0, len(params)*2)
+	for name, value := range params {
+		pairs = append(pairs, "{"+name+"}", formatParam(value))
+	}
+
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+func formatParam(value interface{}) string {
+	switch v := value.(type) {
+	case []string:
+		return strings.Join(v, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,11 @@
+package i18n
+
+import "testing"
+
+// Every validation and response code needs a message in each shipped locale; anything
+// missing would silently fall back to English.
+func TestCataloguesComplete(t *testing.T) {
+	for _, missing := range Missing() {
+		t.Errorf("missing translation for %s", missing)
+	}
+}
@@ -0,0 +1,188 @@
+package i18n
+
+import "github.com/goddhi/zeliz-movie/internal/validator"
+
+// catalogues maps a locale to its messages, keyed by validation or response code.
+// Placeholders in braces are filled from the params recorded with the error.
+var catalogues = map[string]map[string]string{
+	"en": {
+		validator.CodeInvalid:      "is invalid",
+		validator.CodeRequired:     "must be provided",
+		validator.CodeMinLength:    "must be at least {min} characters long",
+		validator.CodeMaxLength:    "must not be more than {max} characters long",
+		validator.CodeMinBytes:     "must be at least {min} bytes long",
+		validator.CodeMaxBytes:     "must not be more than {max} bytes long",
+		validator.CodeMinValue:     "must be at least {min}",
+		validator.CodeMaxValue:     "must not be more than {max}",
+		validator.CodeOutOfRange:   "must be between {min} and {max}",
+		validator.CodeInFuture:     "must not be in the future",
+		validator.CodeMinItems:     "must contain at least {min} items",
+		validator.CodeMaxItems:     "must not contain more than {max} items",
+		validator.CodeDuplicate:    "must not contain duplicate values",
+		validator.CodeTaken:        "is already in use",
+		validator.CodeTakenInTrash: "is already assigned to movie {movie_id}, which is in the trash",
+		validator.CodeDoesNotExist: "does not exist",
+		validator.CodeNotInteger:   "must be an integer value",
+		validator.CodeNotNumber:    "must be a number",
+		validator.CodeNotPermitted: "must be one of: {allowed}",
+		validator.CodeUnknown:      "{value} is not a known value",
+		validator.CodeSuggestion:   "{value} is not a known value, did you mean {suggestion}?",
+		validator.CodeProfanity:    "must not contain offensive language",
+		validator.CodeNotTimestamp: "must be an RFC 3339 timestamp or a YYYY-MM-DD date",
+		validator.CodeNotAfter:     "must be after {after}",
+
+		validator.CodeSyntax:             "unexpected {token} at position {position}",
+		validator.CodeUnknownField:       "{token} at position {position} is not a field you can filter on",
+		validator.CodeOperatorNotAllowed: "{token} at position {position} can't be used with {field}",
+		validator.CodeInvalidValue:       "{token} at position {position} is not a valid value for {field}",
+
+		MsgServerError:      "the server encountered a problem and could not process your request",
+		MsgNotFound:         "the requested resource could not be found",
+		MsgMethodNotAllowed: "the {method} method is not supported for this resource",
+		MsgEditConflict:     "unable to update the record due to an edit conflic, please try again",
+
+		MsgIdempotencyKeyReused:     "the Idempotency-Key has already been used for a different request",
+		MsgIdempotencyKeyInProgress: "a request with this Idempotency-Key is still being processed, please try again later",
+
+		MsgGenreInUse: "the genre is still used by movies, merge it into another genre instead",
+
+		MsgInvalidCredentials:         "invalid authentication credentials",
+		MsgInvalidAuthenticationToken: "invalid or missing authentication token",
+		MsgAuthenticationRequired:     "you must be authenticated to access this resource",
+		MsgRatingExists:               "you have already rated this movie, use PUT to change your rating",
+		MsgNotPermitted:               "your user account doesn't have the necessary permissions to access this resource",
+		MsgReviewExists:               "you have already reviewed this movie, edit your existing review instead",
+
+		MsgRateLimitExceeded: "you have sent too many requests, please slow down and try again shortly",
+
+		MsgBadRequest:         "the request could not be understood",
+		MsgBodyMalformed:      "body contains badly-formed JSON",
+		MsgBodyMalformedAt:    "body contains badly-formed JSON (at character {offset})",
+		MsgBodyWrongType:      "body contains incorrect JSON type for field {field}",
+		MsgBodyWrongTypeAt:    "body contains incorrect JSON type (at character {offset})",
+		MsgBodyEmpty:          "body must not be empty",
+		MsgBodyUnknownKey:     "body contains unknown key {key}",
+		MsgBodyTooLarge:       "body must not be larger than {max} bytes",
+		MsgBodyMultipleValues: "body must only contain a single JSON value",
+	},
+	"fr": {
+		validator.CodeInvalid:      "n'est pas valide",
+		validator.CodeRequired:     "doit être renseigné",
+		validator.CodeMinLength:    "doit contenir au moins {min} caractères",
+		validator.CodeMaxLength:    "ne doit pas dépasser {max} caractères",
+		validator.CodeMinBytes:     "doit contenir au moins {min} octets",
+		validator.CodeMaxBytes:     "ne doit pas dépasser {max} octets",
+		validator.CodeMinValue:     "doit être supérieur ou égal à {min}",
+		validator.CodeMaxValue:     "ne doit pas dépasser {max}",
+		validator.CodeOutOfRange:   "doit être compris entre {min} et {max}",
+		validator.CodeInFuture:     "ne doit pas être dans le futur",
+		validator.CodeMinItems:     "doit contenir au moins {min} élément(s)",
+		validator.CodeMaxItems:     "ne doit pas contenir plus de {max} éléments",
+		validator.CodeDuplicate:    "ne doit pas contenir de valeurs en double",
+		validator.CodeTaken:        "est déjà utilisé",
+		validator.CodeTakenInTrash: "est déjà attribué au film {movie_id}, qui est dans la corbeille",
+		validator.CodeDoesNotExist: "n'existe pas",
+		validator.CodeNotInteger:   "doit être un nombre entier",
+		validator.CodeNotNumber:    "doit être un nombre",
+		validator.CodeNotPermitted: "doit être l'une des valeurs suivantes : {allowed}",
+		validator.CodeUnknown:      "{value} n'est pas une valeur connue",
+		validator.CodeSuggestion:   "{value} n'est pas une valeur connue, vouliez-vous dire {suggestion} ?",
+		validator.CodeProfanity:    "ne doit pas contenir de propos injurieux",
+		validator.CodeNotTimestamp: "doit être un horodatage RFC 3339 ou une date AAAA-MM-JJ",
+		validator.CodeNotAfter:     "doit être postérieur à {after}",
+
+		validator.CodeSyntax:             "{token} inattendu à la position {position}",
+		validator.CodeUnknownField:       "{token} à la position {position} n'est pas un champ filtrable",
+		validator.CodeOperatorNotAllowed: "{token} à la position {position} ne peut pas être utilisé avec {field}",
+		validator.CodeInvalidValue:       "{token} à la position {position} n'est pas une valeur valide pour {field}",
+
+		MsgServerError:      "le serveur a rencontré un problème et n'a pas pu traiter votre requête",
+		MsgNotFound:         "la ressource demandée est introuvable",
+		MsgMethodNotAllowed: "la méthode {method} n'est pas prise en charge pour cette ressource",
+		MsgEditConflict:     "impossible de mettre à jour l'enregistrement à cause d'un conflit de modification, veuillez réessayer",
+
+		MsgIdempotencyKeyReused:     "l'Idempotency-Key a déjà été utilisée pour une autre requête",
+		MsgIdempotencyKeyInProgress: "une requête avec cette Idempotency-Key est encore en cours de traitement, veuillez réessayer plus tard",
+
+		MsgGenreInUse: "le genre est encore utilisé par des films, fusionnez-le plutôt avec un autre genre",
+
+		MsgInvalidCredentials:         "identifiants de connexion invalides",
+		MsgInvalidAuthenticationToken: "jeton d'authentification invalide ou manquant",
+		MsgAuthenticationRequired:     "vous devez être authentifié pour accéder à cette ressource",
+		MsgRatingExists:               "vous avez déjà noté ce film, utilisez PUT pour modifier votre note",
+		MsgNotPermitted:               "votre compte n'a pas les autorisations nécessaires pour accéder à cette ressource",
+		MsgReviewExists:               "vous avez déjà rédigé une critique de ce film, modifiez plutôt votre critique existante",
+
+		MsgRateLimitExceeded: "vous avez envoyé trop de requêtes, veuillez ralentir et réessayer dans un instant",
+
+		MsgBadRequest:         "la requête n'a pas pu être comprise",
+		MsgBodyMalformed:      "le corps contient du JSON mal formé",
+		MsgBodyMalformedAt:    "le corps contient du JSON mal formé (au caractère {offset})",
+		MsgBodyWrongType:      "le corps contient un type JSON incorrect pour le champ {field}",
+		MsgBodyWrongTypeAt:    "le corps contient un type JSON incorrect (au caractère {offset})",
+		MsgBodyEmpty:          "le corps ne doit pas être vide",
+		MsgBodyUnknownKey:     "le corps contient la clé inconnue {key}",
+		MsgBodyTooLarge:       "le corps ne doit pas dépasser {max} octets",
+		MsgBodyMultipleValues: "le corps ne doit contenir qu'une seule valeur JSON",
+	},
+	"es": {
+		validator.CodeInvalid:      "no es válido",
+		validator.CodeRequired:     "es obligatorio",
+		validator.CodeMinLength:    "debe tener al menos {min} caracteres",
+		validator.CodeMaxLength:    "no debe superar los {max} caracteres",
+		validator.CodeMinBytes:     "debe tener al menos {min} bytes",
+		validator.CodeMaxBytes:     "no debe superar los {max} bytes",
+		validator.CodeMinValue:     "debe ser mayor o igual que {min}",
+		validator.CodeMaxValue:     "no debe ser mayor que {max}",
+		validator.CodeOutOfRange:   "debe estar entre {min} y {max}",
+		validator.CodeInFuture:     "no debe estar en el futuro",
+		validator.CodeMinItems:     "debe contener al menos {min} elemento(s)",
+		validator.CodeMaxItems:     "no debe contener más de {max} elementos",
+		validator.CodeDuplicate:    "no debe contener valores duplicados",
+		validator.CodeTaken:        "ya está en uso",
+		validator.CodeTakenInTrash: "ya está asignado a la película {movie_id}, que está en la papelera",
+		validator.CodeDoesNotExist: "no existe",
+		validator.CodeNotInteger:   "debe ser un número entero",
+		validator.CodeNotNumber:    "debe ser un número",
+		validator.CodeNotPermitted: "debe ser uno de: {allowed}",
+		validator.CodeUnknown:      "{value} no es un valor conocido",
+		validator.CodeSuggestion:   "{value} no es un valor conocido, ¿quiso decir {suggestion}?",
+		validator.CodeProfanity:    "no debe contener lenguaje ofensivo",
+		validator.CodeNotTimestamp: "debe ser una marca de tiempo RFC 3339 o una fecha AAAA-MM-DD",
+		validator.CodeNotAfter:     "debe ser posterior a {after}",
+
+		validator.CodeSyntax:             "{token} inesperado en la posición {position}",
+		validator.CodeUnknownField:       "{token} en la posición {position} no es un campo por el que se pueda filtrar",
+		validator.CodeOperatorNotAllowed: "{token} en la posición {position} no se puede usar con {field}",
+		validator.CodeInvalidValue:       "{token} en la posición {position} no es un valor válido para {field}",
+
+		MsgServerError:      "el servidor encontró un problema y no pudo procesar su solicitud",
+		MsgNotFound:         "no se pudo encontrar el recurso solicitado",
+		MsgMethodNotAllowed: "el método {method} no está permitido para este recurso",
+		MsgEditConflict:     "no se pudo actualizar el registro debido a un conflicto de edición, inténtelo de nuevo",
+
+		MsgIdempotencyKeyReused:     "la Idempotency-Key ya se ha utilizado para una solicitud diferente",
+		MsgIdempotencyKeyInProgress: "una solicitud con esta Idempotency-Key todavía se está procesando, inténtelo más tarde",
+
+		MsgGenreInUse: "el género todavía lo usan algunas películas, fusiónelo con otro género en su lugar",
+
+		MsgInvalidCredentials:         "credenciales de autenticación no válidas",
+		MsgInvalidAuthenticationToken: "token de autenticación no válido o ausente",
+		MsgAuthenticationRequired:     "debe estar autenticado para acceder a este recurso",
+		MsgRatingExists:               "ya ha valorado esta película, use PUT para cambiar su valoración",
+		MsgNotPermitted:               "su cuenta de usuario no tiene los permisos necesarios para acceder a este recurso",
+		MsgReviewExists:               "ya ha escrito una reseña de esta película, edite su reseña existente en su lugar",
+
+		MsgRateLimitExceeded: "ha enviado demasiadas solicitudes, reduzca el ritmo e inténtelo de nuevo en breve",
+
+		MsgBadRequest:         "no se pudo entender la solicitud",
+		MsgBodyMalformed:      "el cuerpo contiene JSON mal formado",
+		MsgBodyMalformedAt:    "el cuerpo contiene JSON mal formado (en el carácter {offset})",
+		MsgBodyWrongType:      "el cuerpo contiene un tipo JSON incorrecto para el campo {field}",
+		MsgBodyWrongTypeAt:    "el cuerpo contiene un tipo JSON incorrecto (en el carácter {offset})",
+		MsgBodyEmpty:          "el cuerpo no debe estar vacío",
+		MsgBodyUnknownKey:     "el cuerpo contiene la clave desconocida {key}",
+		MsgBodyTooLarge:       "el cuerpo no debe superar los {max} bytes",
+		MsgBodyMultipleValues: "el cuerpo solo debe contener un único valor JSON",
+	},
+}
@@ -1,71 +1,202 @@
-package validator
-
-
-
-import (
-	"regexp"
-)
-
-// a regular expression for sanity checking the format of email addresses
-var (
-	EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
-)
-// Validator type which contains a map of validation errors.
-type Validator struct {
-	Errors map[string]string
-}
-
-// New is a helper which creates a new Validator instance with an empty errors map.
-func New() *Validator {
-	return &Validator{Errors: make(map[string]string)}
-}
-
-// Valid returns true if the errors map doesn't contain any entries.
-
-func (v *Validator) Valid() bool {
-	return len(v.Errors) == 0 
-}
-
-// AddError adds an error message to the map (so long as no entry already exists for
-// the given key
-// This ensures that duplicate error messages for the same key are avoided
-func (v *Validator) AddError(key, message string) {
-	if _, exists := v.Errors[key]; !exists {
-		v.Errors[key] = message
-	}
-}
-
-// Check: adds an error message to the map only if a validation check is not 'ok'.
-func (v *Validator) Check(ok bool, key, message string) {
-	if !ok {
-		v.AddError(key, message)
-	}
-}
-
-// In returns true if a specific value is in a list of strings.
-func In(value string, list ...string) bool {
-	for i := range list {
-		if value == list[i] {
-			return true
-		}
-	}
-	return false
-}
-
-// Matches returns true if a string value matches a specific regexp pattern.
-func Matches(value string, rx *regexp.Regexp) bool{
-	return rx.MatchString(value)
-}
-
-// Unique returns true if all string values in a slice are unique.
-func Unique(values []string) bool {
-	uniqueValues := make(map[string]bool)
-
-	for _, value := range values {
-		uniqueValues[value] = true
-	}
-	return len(values) == len(uniqueValues)
-}
-
-
-
+package validator
+
+
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// a regular expression for sanity checking the format of email addresses
+var (
+	EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+)
+
+// Stable, machine-readable codes describing why a check failed. Clients can key
+// their own (localised) messages off these instead of parsing the English text.
+const (
+	CodeInvalid      = "invalid"
+	CodeRequired     = "required"
+	CodeMinLength    = "min_length"
+	CodeMaxLength    = "max_length"
+	CodeMinBytes     = "min_bytes"
+	CodeMaxBytes     = "max_bytes"
+	CodeMinValue     = "min_value"
+	CodeMaxValue     = "max_value"
+	CodeOutOfRange   = "out_of_range"
+	CodeInFuture     = "in_future"
+	CodeMinItems     = "min_items"
+	CodeMaxItems     = "max_items"
+	CodeDuplicate    = "duplicate"
+	CodeTaken        = "taken"
+	CodeTakenInTrash = "taken_in_trash"
+	CodeDoesNotExist = "does_not_exist"
+	CodeNotInteger   = "not_integer"
+	CodeNotNumber    = "not_number"
+	CodeNotPermitted = "not_permitted"
+	CodeUnknown      = "unknown"
+	CodeSuggestion   = "unknown_did_you_mean"
+	CodeProfanity    = "profanity"
+	CodeNotTimestamp = "not_timestamp"
+	CodeNotAfter     = "not_after"
+
+	// Codes for a rejected filter expression. Their params include the position of the
+	// offending token and the token itself.
+	CodeSyntax             = "syntax_error"
+	CodeUnknownField       = "unknown_field"
+	CodeOperatorNotAllowed = "operator_not_allowed"
+	CodeInvalidValue       = "invalid_value"
+)
+
+// Codes lists every code above, so other packages can check they handle all of them.
+var Codes = []string{
+	CodeInvalid,
+	CodeRequired,
+	CodeMinLength,
+	CodeMaxLength,
+	CodeMinBytes,
+	CodeMaxBytes,
+	CodeMinValue,
+	CodeMaxValue,
+	CodeOutOfRange,
+	CodeInFuture,
+	CodeMinItems,
+	CodeMaxItems,
+	CodeDuplicate,
+	CodeTaken,
+	CodeTakenInTrash,
+	CodeDoesNotExist,
+	CodeNotInteger,
+	CodeNotNumber,
+	CodeNotPermitted,
+	CodeUnknown,
+	CodeSuggestion,
+	CodeProfanity,
+	CodeNotTimestamp,
+	CodeNotAfter,
+	CodeSyntax,
+	CodeUnknownField,
+	CodeOperatorNotAllowed,
+	CodeInvalidValue,
+}
+
+// Params holds the values a check was made against (limits, allowed values, ...).
+type Params map[string]interface{}
+
+// FieldError describes a single failed check for a field.
+type FieldError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Params  Params `json:"params,omitempty"`
+}
+
+// Validator type which contains a map of validation errors.
+// Errors keeps the first message for each key (the original response format), while
+// Details records every failed check for the key along with its code and params.
+type Validator struct {
+	Errors  map[string]string
+	Details map[string][]FieldError
+}
+
+// New is a helper which creates a new Validator instance with empty errors maps.
+func New() *Validator {
+	return &Validator{
+		Errors:  make(map[string]string),
+		Details: make(map[string][]FieldError),
+	}
+}
+
+// Valid returns true if the errors map doesn't contain any entries.
+
+func (v *Validator) Valid() bool {
+	return len(v.Errors) == 0
+}
+
+// AddError adds an error message to the map (so long as no entry already exists for
+// the given key
+// This ensures that duplicate error messages for the same key are avoided
+// The error is recorded with the generic "invalid" code.
+func (v *Validator) AddError(key, message string) {
+	v.AddCodedError(key, CodeInvalid, message, nil)
+}
+
+// AddCodedError records a failed check with its code and params. The first message for
+// a key is also kept in the Errors map; later ones are only added to Details.
+func (v *Validator) AddCodedError(key, code, message string, params Params) {
+	if _, exists := v.Errors[key]; !exists {
+		v.Errors[key] = message
+	}
+
+	v.Details[key] = append(v.Details[key], FieldError{Code: code, Message: message, Params: params})
+}
+
+// Check: adds an error message to the map only if a validation check is not 'ok'.
+func (v *Validator) Check(ok bool, key, message string) {
+	if !ok {
+		v.AddError(key, message)
+	}
+}
+
+// CheckCode is like Check but records the failure with a code and params.
+func (v *Validator) CheckCode(ok bool, key, code, message string, params Params) {
+	if !ok {
+		v.AddCodedError(key, code, message, params)
+	}
+}
+
+// In returns true if a specific value is in a list of strings.
+func In(value string, list ...string) bool {
+	for i := range list {
+		if value == list[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches returns true if a string value matches a specific regexp pattern.
+func Matches(value string, rx *regexp.Regexp) bool{
+	return rx.MatchString(value)
+}
+
+// NoWordsFrom returns true if none of the words in a text are in the list or built on
+// one. A word counts if it starts or ends with a listed word, which catches inflections
+// ("fuckers", "shitty") and compounds ("bullshit"). Words are compared
+// case-insensitively, and a listed word never matches in the middle of another, so
+// "Scunthorpe" is fine; listed words should be long enough that they don't begin or end
+// everyday words. Words that do can be mapped to false in the list to allow them.
+func NoWordsFrom(text string, list map[string]bool) bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for _, word := range words {
+		if banned, ok := list[word]; ok {
+			if banned {
+				return false
+			}
+			continue
+		}
+
+		for listed, banned := range list {
+			if banned && (strings.HasPrefix(word, listed) || strings.HasSuffix(word, listed)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Unique returns true if all string values in a slice are unique.
+func Unique(values []string) bool {
+	uniqueValues := make(map[string]bool)
+
+	for _, value := range values {
+		uniqueValues[value] = true
+	}
+	return len(values) == len(uniqueValues)
+}
+
+
+
@@ -0,0 +1,33 @@
+package validator
+
+import "testing"
+
+func TestNoWordsFrom(t *testing.T) {
+	list := map[string]bool{"darn": true, "heck": true, "darnley": false}
+
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"", true},
+		{"a perfectly clean review", true},
+		{"darn", false},
+		{"DARN it", false},
+		{"well, darn!", false},
+		{"darned", false},        // starts with a listed word
+		{"darnedest", false},     // starts with a listed word
+		{"whatthedarn", false},   // ends with a listed word
+		{"what-the-heck", false}, // split on punctuation
+		{"checkers", true},       // a listed word in the middle doesn't count
+		{"shecked", true},
+		{"dar n", true},
+		{"Lord Darnley", true}, // allowed by a false entry
+		{"darnleys", false},    // only the exact word is allowed
+	}
+
+	for _, tt := range tests {
+		if got := NoWordsFrom(tt.text, list); got != tt.want {
+			t.Errorf("NoWordsFrom(%q) = %t; want %t", tt.text, got, tt.want)
+		}
+	}
+}
@@ -1 +1 @@
-DROP TABLE IF EXISTS movies;
+DROP TABLE IF EXISTS movies;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+key text PRIMARY KEY,
+fingerprint bytea NOT NULL,
+status_code integer,
+headers jsonb,
+body bytea,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+expires_at timestamp(0) with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
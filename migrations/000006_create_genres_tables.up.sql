@@ -0,0 +1,83 @@
+CREATE TABLE IF NOT EXISTS genres (
+slug text PRIMARY KEY,
+name text NOT NULL,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS genre_aliases (
+alias text PRIMARY KEY,
+slug text NOT NULL REFERENCES genres ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS genre_aliases_slug_idx ON genre_aliases (slug);
+
+INSERT INTO genres (slug, name) VALUES
+('action', 'Action'),
+('adventure', 'Adventure'),
+('animation', 'Animation'),
+('biography', 'Biography'),
+('comedy', 'Comedy'),
+('crime', 'Crime'),
+('documentary', 'Documentary'),
+('drama', 'Drama'),
+('family', 'Family'),
+('fantasy', 'Fantasy'),
+('film-noir', 'Film Noir'),
+('game-show', 'Game Show'),
+('history', 'History'),
+('horror', 'Horror'),
+('music', 'Music'),
+('musical', 'Musical'),
+('mystery', 'Mystery'),
+('news', 'News'),
+('reality-tv', 'Reality TV'),
+('romance', 'Romance'),
+('science-fiction', 'Science Fiction'),
+('short', 'Short'),
+('sport', 'Sport'),
+('talk-show', 'Talk Show'),
+('thriller', 'Thriller'),
+('war', 'War'),
+('western', 'Western')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO genre_aliases (alias, slug) VALUES
+('sci-fi', 'science-fiction'),
+('scifi', 'science-fiction'),
+('sf', 'science-fiction'),
+('noir', 'film-noir'),
+('animated', 'animation'),
+('cartoon', 'animation'),
+('biopic', 'biography'),
+('documentaries', 'documentary'),
+('doc', 'documentary'),
+('sports', 'sport'),
+('romantic', 'romance'),
+('historical', 'history'),
+('musicals', 'musical'),
+('westerns', 'western')
+ON CONFLICT DO NOTHING;
+
+-- Back-fill: every genre already used by a movie is reduced to its key (lower case, runs
+-- of anything but letters and digits replaced by a hyphen), resolved through the aliases,
+-- and added to the vocabulary if it still isn't known.
+INSERT INTO genres (slug, name)
+SELECT DISTINCT ON (k.key) k.key, initcap(trim(g))
+FROM movies, unnest(movies.genres) AS g,
+LATERAL (SELECT trim(both '-' from regexp_replace(lower(g), '[^a-z0-9]+', '-', 'g')) AS key) k
+WHERE k.key <> ''
+AND NOT EXISTS (SELECT 1 FROM genre_aliases a WHERE a.alias = k.key)
+ON CONFLICT DO NOTHING;
+
+UPDATE movies SET genres = ARRAY(
+SELECT n.slug
+FROM (
+SELECT COALESCE(a.slug, NULLIF(k.key, ''), u.g) AS slug, min(u.ord) AS ord
+FROM unnest(movies.genres) WITH ORDINALITY AS u(g, ord)
+CROSS JOIN LATERAL (SELECT trim(both '-' from regexp_replace(lower(u.g), '[^a-z0-9]+', '-', 'g')) AS key) k
+LEFT JOIN genre_aliases a ON a.alias = k.key
+GROUP BY 1
+) n
+ORDER BY n.ord
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS ratings (
+user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+score smallint NOT NULL CHECK (score BETWEEN 1 AND 10),
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+PRIMARY KEY (user_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS ratings_movie_id_idx ON ratings (movie_id);
+
+-- Aggregates kept on the movie itself so the listing can sort on them cheaply. They are
+-- recalculated whenever one of the movie's ratings changes.
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS average_rating numeric(4,2) NOT NULL DEFAULT 0;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS rating_count integer NOT NULL DEFAULT 0;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS reviews (
+id bigserial PRIMARY KEY,
+movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+body text NOT NULL,
+status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+moderation_note text NOT NULL DEFAULT '',
+moderated_by bigint REFERENCES users ON DELETE SET NULL,
+moderated_at timestamp(0) with time zone,
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+version integer NOT NULL DEFAULT 1,
+UNIQUE (movie_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS reviews_movie_id_status_idx ON reviews (movie_id, status, created_at);
+CREATE INDEX IF NOT EXISTS reviews_status_idx ON reviews (status, created_at);
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS lists (
+id bigserial PRIMARY KEY,
+user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+name text NOT NULL,
+description text NOT NULL DEFAULT '',
+visibility text NOT NULL DEFAULT 'private' CHECK (visibility IN ('public', 'private')),
+created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS lists_user_id_idx ON lists (user_id);
+
+-- Positions run from 1. Moving an item shifts its neighbours one at a time, so the
+-- uniqueness of positions is only checked when the transaction commits. Deleting a movie
+-- can leave a gap, which is closed the next time the list's items are changed.
+CREATE TABLE IF NOT EXISTS list_items (
+list_id bigint NOT NULL REFERENCES lists ON DELETE CASCADE,
+movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+position integer NOT NULL CHECK (position >= 1),
+added_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+PRIMARY KEY (list_id, movie_id),
+CONSTRAINT list_items_position_key UNIQUE (list_id, position) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE INDEX IF NOT EXISTS list_items_movie_id_idx ON list_items (movie_id);
@@ -0,0 +1,6 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- A GiST rather than GIN index, so it can serve the nearest match ordering (<->) used
+-- for "did you mean" suggestions as well as the % operator that match=fuzzy searches
+-- and those suggestions filter with. The similarity() function can't use the index.
+CREATE INDEX IF NOT EXISTS movies_title_trgm_idx ON movies USING GIST (title gist_trgm_ops);
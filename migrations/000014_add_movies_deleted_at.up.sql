@@ -0,0 +1,6 @@
+-- Deleted movies are kept in the trash, with the time they were deleted, until they're
+-- restored or purged.
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+
+-- Only the trash is looked up by deletion time, so only deleted movies are indexed.
+CREATE INDEX IF NOT EXISTS movies_deleted_at_idx ON movies (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1 @@
+INSERT INTO permissions (code) VALUES ('genres:manage') ON CONFLICT DO NOTHING;
@@ -0,0 +1 @@
+INSERT INTO permissions (code) VALUES ('movies:manage') ON CONFLICT DO NOTHING;
@@ -0,0 +1 @@
+INSERT INTO permissions (code) VALUES ('people:manage') ON CONFLICT DO NOTHING;